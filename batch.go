@@ -0,0 +1,123 @@
+package gofmcon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrBatchSkipped is the error of a batch query which was not sent
+// because the batch had been stopped before its turn
+var ErrBatchSkipped = errors.New("gofmcon.Batch: query skipped")
+
+// BatchOptions configures execution of FMConnector.Batch
+type BatchOptions struct {
+	// Concurrency is the maximum number of queries sent at the same time.
+	// Values less than 1 mean queries are sent one by one
+	Concurrency int
+	// StopOnError stops sending new queries after the first failed one.
+	// Queries already in flight are not canceled
+	StopOnError bool
+	// Progress, if set, is called after every finished query with
+	// the number of finished queries and the size of the batch.
+	// Calls are never made concurrently
+	Progress func(done, total int)
+}
+
+// BatchResult is the outcome of a single query of the batch
+type BatchResult struct {
+	Query     *FMQuery
+	Resultset FMResultset
+	Err       error
+}
+
+// Batch executes queries with bounded parallelism and returns their
+// results in the same order as the queries. Every query gets its own
+// result, queries which were not sent have ErrBatchSkipped as the error.
+// The returned error is not nil only if the batch was stopped, either
+// because of StopOnError or because ctx is done
+func (fmc *FMConnector) Batch(ctx context.Context, queries []*FMQuery, opts BatchOptions) ([]BatchResult, error) {
+	results := make([]BatchResult, len(queries))
+	for i, q := range queries {
+		results[i] = BatchResult{Query: q, Err: ErrBatchSkipped}
+	}
+
+	err := runBounded(ctx, len(queries), opts, func(ctx context.Context, i int) error {
+		rs, err := fmc.Query(ctx, queries[i])
+		results[i].Resultset = rs
+		results[i].Err = err
+		return err
+	})
+	if err != nil {
+		return results, fmt.Errorf("gofmcon.Batch: %w", err)
+	}
+
+	return results, nil
+}
+
+// runBounded calls fn for every index from 0 to n-1 with at most
+// opts.Concurrency calls running at the same time
+func runBounded(ctx context.Context, n int, opts BatchOptions, fn func(ctx context.Context, i int) error) error {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		done     int
+		firstErr error
+		errIdx   int
+		sem      = make(chan struct{}, concurrency)
+	)
+
+loop:
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			break loop
+		case sem <- struct{}{}:
+		}
+
+		mu.Lock()
+		stop := opts.StopOnError && firstErr != nil
+		mu.Unlock()
+		if stop || ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			err := fn(ctx, i)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && firstErr == nil {
+				firstErr = err
+				errIdx = i
+			}
+			done++
+			if opts.Progress != nil {
+				opts.Progress(done, n)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if done < n && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if opts.StopOnError && firstErr != nil {
+		return fmt.Errorf("item %d: %w", errIdx, firstErr)
+	}
+
+	return nil
+}
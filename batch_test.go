@@ -0,0 +1,84 @@
+package gofmcon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		inFlight int
+		maxSeen  int
+		full     = make(chan struct{})
+		fullOnce sync.Once
+	)
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxSeen {
+			maxSeen = inFlight
+		}
+		if inFlight == 3 {
+			fullOnce.Do(func() { close(full) })
+		}
+		mu.Unlock()
+		// the first requests wait until the concurrency limit is reached
+		select {
+		case <-full:
+		case <-time.After(5 * time.Second):
+			t.Error("requests were not sent concurrently")
+		}
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		if r.URL.Query().Get("name") == "bad" {
+			_, _ = w.Write([]byte(`<fmresultset><error code="504"/></fmresultset>`))
+			return
+		}
+		_, _ = w.Write([]byte(singleRecordXML))
+	})
+
+	var queries []*FMQuery
+	for i := 0; i < 10; i++ {
+		name := "good"
+		if i == 3 {
+			name = "bad"
+		}
+		queries = append(queries, NewFMQuery("db", "people", New).WithFields(FMQueryField{Name: "name", Value: name}))
+	}
+
+	var progress []int
+	results, err := conn.Batch(context.Background(), queries, BatchOptions{
+		Concurrency: 3,
+		Progress:    func(done, total int) { progress = append(progress, done) },
+	})
+	assert.NoError(t, err)
+	assert.Len(t, results, 10)
+	assert.Equal(t, 3, maxSeen)
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, progress)
+	for i, res := range results {
+		assert.Same(t, queries[i], res.Query)
+		if i == 3 {
+			assert.Error(t, res.Err)
+			continue
+		}
+		assert.NoError(t, res.Err)
+		assert.Equal(t, 1, res.Resultset.Resultset.Records[0].ID)
+	}
+
+	results, err = conn.Batch(context.Background(), queries, BatchOptions{StopOnError: true})
+	assert.Error(t, err)
+	assert.NoError(t, results[2].Err)
+	assert.Error(t, results[3].Err)
+	for _, res := range results[4:] {
+		assert.True(t, errors.Is(res.Err, ErrBatchSkipped))
+	}
+}
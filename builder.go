@@ -0,0 +1,169 @@
+package gofmcon
+
+// Clone returns a deep copy of the query, so the copy
+// can be changed without affecting the original
+func (q *FMQuery) Clone() *FMQuery {
+	c := *q
+
+	if q.QueryFields != nil {
+		c.QueryFields = make([]FMQueryFieldGroup, len(q.QueryFields))
+		for i, g := range q.QueryFields {
+			c.QueryFields[i] = FMQueryFieldGroup{Op: g.Op, Fields: append([]FMQueryField(nil), g.Fields...)}
+		}
+	}
+	c.SortFields = append([]FMSortField(nil), q.SortFields...)
+	c.ResponseFields = append([]string(nil), q.ResponseFields...)
+
+	if q.Query != nil {
+		c.Query = make(map[string]string, len(q.Query))
+		for k, v := range q.Query {
+			c.Query[k] = v
+		}
+	}
+
+	return &c
+}
+
+// FMQueryBuilder is an immutable variant of the FMQuery builder methods.
+// Every method returns a new builder and leaves the receiver as it was,
+// so a base query can be shared, e.g. between goroutines, and extended
+// differently for every request
+//
+//	base := NewFMQueryBuilder("db", "orders", Find).WithSortFields(FMSortField{Name: "date", Order: Descending})
+//	q := base.WithFields(Eq(customerID).For("customer_id")).Max(10).Build()
+type FMQueryBuilder struct {
+	q *FMQuery
+}
+
+// NewFMQueryBuilder creates new FMQueryBuilder object
+func NewFMQueryBuilder(database string, layout string, action FMAction) FMQueryBuilder {
+	return FMQueryBuilder{q: NewFMQuery(database, layout, action)}
+}
+
+// Builder returns FMQueryBuilder starting from a copy of the query
+func (q *FMQuery) Builder() FMQueryBuilder {
+	return FMQueryBuilder{q: q.Clone()}
+}
+
+// Build returns a new FMQuery, changing it doesn't affect the builder
+func (b FMQueryBuilder) Build() *FMQuery {
+	if b.q == nil {
+		return NewFMQuery("", "", "")
+	}
+	return b.q.Clone()
+}
+
+func (b FMQueryBuilder) with(fn func(q *FMQuery)) FMQueryBuilder {
+	q := b.Build()
+	fn(q)
+	return FMQueryBuilder{q: q}
+}
+
+// WithRecordID see FMQuery.WithRecordID
+func (b FMQueryBuilder) WithRecordID(id int) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithRecordID(id) })
+}
+
+// WithModID see FMQuery.WithModID
+func (b FMQueryBuilder) WithModID(id int) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithModID(id) })
+}
+
+// WithFieldGroups see FMQuery.WithFieldGroups
+func (b FMQueryBuilder) WithFieldGroups(fieldGroups ...FMQueryFieldGroup) FMQueryBuilder {
+	return b.with(func(q *FMQuery) {
+		for _, g := range fieldGroups {
+			q.WithFieldGroups(FMQueryFieldGroup{Op: g.Op, Fields: append([]FMQueryField(nil), g.Fields...)})
+		}
+	})
+}
+
+// WithFields see FMQuery.WithFields
+func (b FMQueryBuilder) WithFields(fields ...FMQueryField) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithFields(append([]FMQueryField(nil), fields...)...) })
+}
+
+// WithExpr see FMQuery.WithExpr
+func (b FMQueryBuilder) WithExpr(e Expr) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithExpr(e) })
+}
+
+// WithFilter see FMQuery.WithFilter
+func (b FMQueryBuilder) WithFilter(filter string) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithFilter(filter) })
+}
+
+// WithSortFields see FMQuery.WithSortFields
+func (b FMQueryBuilder) WithSortFields(sortFields ...FMSortField) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithSortFields(sortFields...) })
+}
+
+// WithPreSortScript see FMQuery.WithPreSortScript
+func (b FMQueryBuilder) WithPreSortScript(script, param string) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithPreSortScript(script, param) })
+}
+
+// WithPreFindScript see FMQuery.WithPreFindScript
+func (b FMQueryBuilder) WithPreFindScript(script, param string) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithPreFindScript(script, param) })
+}
+
+// WithPostFindScript see FMQuery.WithPostFindScript
+func (b FMQueryBuilder) WithPostFindScript(script, param string) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithPostFindScript(script, param) })
+}
+
+// WithPreSortScriptJSON see FMQuery.WithPreSortScriptJSON
+func (b FMQueryBuilder) WithPreSortScriptJSON(script string, param interface{}) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithPreSortScriptJSON(script, param) })
+}
+
+// WithPreFindScriptJSON see FMQuery.WithPreFindScriptJSON
+func (b FMQueryBuilder) WithPreFindScriptJSON(script string, param interface{}) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithPreFindScriptJSON(script, param) })
+}
+
+// WithPostFindScriptJSON see FMQuery.WithPostFindScriptJSON
+func (b FMQueryBuilder) WithPostFindScriptJSON(script string, param interface{}) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithPostFindScriptJSON(script, param) })
+}
+
+// WithPreSortScriptList see FMQuery.WithPreSortScriptList
+func (b FMQueryBuilder) WithPreSortScriptList(script string, params ...interface{}) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithPreSortScriptList(script, params...) })
+}
+
+// WithPreFindScriptList see FMQuery.WithPreFindScriptList
+func (b FMQueryBuilder) WithPreFindScriptList(script string, params ...interface{}) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithPreFindScriptList(script, params...) })
+}
+
+// WithPostFindScriptList see FMQuery.WithPostFindScriptList
+func (b FMQueryBuilder) WithPostFindScriptList(script string, params ...interface{}) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithPostFindScriptList(script, params...) })
+}
+
+// WithResponseLayout see FMQuery.WithResponseLayout
+func (b FMQueryBuilder) WithResponseLayout(lay string) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithResponseLayout(lay) })
+}
+
+// WithResponseFields see FMQuery.WithResponseFields
+func (b FMQueryBuilder) WithResponseFields(fields ...string) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithResponseFields(fields...) })
+}
+
+// Max see FMQuery.Max
+func (b FMQueryBuilder) Max(n int) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.Max(n) })
+}
+
+// Skip see FMQuery.Skip
+func (b FMQueryBuilder) Skip(n int) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.Skip(n) })
+}
+
+// WithParam see FMQuery.WithParam
+func (b FMQueryBuilder) WithParam(key, value string) FMQueryBuilder {
+	return b.with(func(q *FMQuery) { q.WithParam(key, value) })
+}
@@ -0,0 +1,78 @@
+package gofmcon
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClone(t *testing.T) {
+	q := NewFMQuery("db", "lay", Find).
+		WithFields(FMQueryField{Name: "name", Value: "John"}).
+		WithSortFields(FMSortField{Name: "name", Order: Ascending}).
+		WithResponseFields("name").
+		WithParam("-lop", "or")
+	q.setErr(errors.New("build error"))
+
+	c := q.Clone()
+	assert.Equal(t, q, c)
+
+	c.QueryFields[0].Fields[0].Value = "Jane"
+	c.WithFields(FMQueryField{Name: "age", Value: "1"})
+	c.SortFields[0].Order = Descending
+	c.ResponseFields[0] = "age"
+	c.Query["-lop"] = "and"
+
+	assert.Equal(t, "John", q.QueryFields[0].Fields[0].Value)
+	assert.Len(t, q.QueryFields, 1)
+	assert.Equal(t, Ascending, q.SortFields[0].Order)
+	assert.Equal(t, []string{"name"}, q.ResponseFields)
+	assert.Equal(t, "or", q.Query["-lop"])
+	assert.EqualError(t, c.Err(), "build error")
+}
+
+func TestFMQueryBuilder(t *testing.T) {
+	base := NewFMQueryBuilder("db", "lay", Find).
+		WithSortFields(FMSortField{Name: "date", Order: Descending}).
+		WithFields(FMQueryField{Name: "status", Value: "open"})
+
+	a := base.WithFields(FMQueryField{Name: "name", Value: "a"}).Max(1).Build()
+	b := base.WithFields(FMQueryField{Name: "name", Value: "b"}).Build()
+
+	assert.Len(t, base.Build().QueryFields, 1)
+	assert.Equal(t, fmAllRecords, base.Build().MaxRecords)
+	assert.Len(t, a.QueryFields, 2)
+	assert.Equal(t, "a", a.QueryFields[1].Fields[0].Value)
+	assert.Equal(t, "b", b.QueryFields[1].Fields[0].Value)
+	assert.Equal(t, 1, a.MaxRecords)
+
+	// changing the built query doesn't change the builder
+	a.QueryFields[0].Fields[0].Value = "closed"
+	a.SortFields[0].Order = Ascending
+	assert.Equal(t, "open", base.Build().QueryFields[0].Fields[0].Value)
+	assert.Equal(t, Descending, base.Build().SortFields[0].Order)
+
+	// builder from an existing query doesn't change the query
+	q := NewFMQuery("db", "lay", FindAll)
+	q.Builder().WithSortFields(FMSortField{Name: "date", Order: Ascending}).WithParam("-lop", "or")
+	assert.Empty(t, q.SortFields)
+	assert.Nil(t, q.Query)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q := base.WithFields(FMQueryField{Name: "name", Value: "x"}).WithResponseFields("name").Build()
+			assert.Len(t, q.QueryFields, 2)
+			assert.Len(t, q.ResponseFields, 1)
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, base.Build().QueryFields, 1)
+
+	assert.True(t, errors.Is(base.WithExpr(OrExpr()).Build().Err(), ErrExprMatchesNone))
+	assert.NoError(t, base.Build().Err())
+}
@@ -0,0 +1,206 @@
+package gofmcon
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache stores results of read-only queries. Every entry is tagged with
+// the database and layout it was fetched from so all entries of a layout
+// can be dropped at once. Implementations must be safe for concurrent use
+type Cache interface {
+	// Get returns the result stored under key if it has not expired
+	Get(key string) (FMResultset, bool)
+	// Set stores the result under key for ttl
+	Set(key string, tag string, rs FMResultset, ttl time.Duration)
+	// Invalidate removes all entries with the given tag
+	Invalidate(tag string)
+}
+
+// CacheConfig configures caching of read-only queries made by FMConnector.
+// Only -findquery and -findall requests without scripts are cached.
+// Any -new, -edit, -delete or -dup request made by the same connector
+// invalidates cached results of its layout, reads in flight during the
+// request are not cached
+type CacheConfig struct {
+	Cache Cache
+	// TTL is used for layouts missing in LayoutTTL
+	TTL time.Duration
+	// LayoutTTL overrides TTL for particular layouts.
+	// Zero or negative value disables caching for the layout
+	LayoutTTL map[string]time.Duration
+}
+
+func (cc *CacheConfig) ttl(layout string) time.Duration {
+	if ttl, ok := cc.LayoutTTL[layout]; ok {
+		return ttl
+	}
+	return cc.TTL
+}
+
+// SetCache enables caching of read-only queries.
+// Passing nil disables caching
+func (fmc *FMConnector) SetCache(cfg *CacheConfig) {
+	fmc.CacheConfig = cfg
+}
+
+func (fmc *FMConnector) cachedQuery(ctx context.Context, q *FMQuery, creds Credentials) (FMResultset, error) {
+	cc := fmc.CacheConfig
+	key := fmc.requestKey(q, creds)
+
+	if rs, ok := cc.Cache.Get(key); ok {
+		return rs.clone(), nil
+	}
+
+	rs, err := fmc.flight.do(key, func() (FMResultset, error) {
+		tag := cacheTag(q)
+		gen := fmc.cacheGens.current(tag)
+		rs, err := fmc.query(ctx, q, creds)
+		if err == nil {
+			if ttl := cc.ttl(q.Layout); ttl > 0 {
+				fmc.cacheGens.set(cc.Cache, gen, key, tag, rs, ttl)
+			}
+		}
+		return rs, err
+	})
+
+	return rs.clone(), err
+}
+
+func cacheTag(q *FMQuery) string {
+	return strings.ToLower(q.Database) + "\n" + strings.ToLower(q.Layout)
+}
+
+// cacheGenerations counts invalidations of every tag, so a read which
+// started before a write doesn't cache its result after the write
+// invalidated the tag
+type cacheGenerations struct {
+	mu   sync.Mutex
+	gens map[string]uint64
+}
+
+// current returns the generation of the tag, taken before the request
+func (g *cacheGenerations) current(tag string) uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.gens[tag]
+}
+
+// invalidate starts a new generation of the tag and drops its entries
+func (g *cacheGenerations) invalidate(c Cache, tag string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.gens == nil {
+		g.gens = map[string]uint64{}
+	}
+	g.gens[tag]++
+	c.Invalidate(tag)
+}
+
+// set stores the result unless the tag was invalidated since the generation
+func (g *cacheGenerations) set(c Cache, gen uint64, key, tag string, rs FMResultset, ttl time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.gens[tag] != gen {
+		return
+	}
+	c.Set(key, tag, rs, ttl)
+}
+
+type lruEntry struct {
+	key     string
+	tag     string
+	rs      FMResultset
+	expires time.Time
+}
+
+// LRUCache is an in-memory Cache which evicts the least recently
+// used entries once it holds more than its size
+type LRUCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+	tags  map[string]map[string]struct{}
+}
+
+// NewLRUCache creates LRUCache holding at most size entries
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{
+		size:  size,
+		ll:    list.New(),
+		items: map[string]*list.Element{},
+		tags:  map[string]map[string]struct{}{},
+	}
+}
+
+// Get implements Cache
+func (c *LRUCache) Get(key string) (FMResultset, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return FMResultset{}, false
+	}
+	entry := el.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(el)
+		return FMResultset{}, false
+	}
+	c.ll.MoveToFront(el)
+
+	return entry.rs, true
+}
+
+// Set implements Cache
+func (c *LRUCache) Set(key string, tag string, rs FMResultset, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+
+	el := c.ll.PushFront(&lruEntry{key: key, tag: tag, rs: rs, expires: time.Now().Add(ttl)})
+	c.items[key] = el
+	if c.tags[tag] == nil {
+		c.tags[tag] = map[string]struct{}{}
+	}
+	c.tags[tag][key] = struct{}{}
+
+	for c.size > 0 && c.ll.Len() > c.size {
+		c.remove(c.ll.Back())
+	}
+}
+
+// Invalidate implements Cache
+func (c *LRUCache) Invalidate(tag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.tags[tag] {
+		c.remove(c.items[key])
+	}
+}
+
+// Len returns the number of entries in the cache, including expired ones
+// not yet evicted
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LRUCache) remove(el *list.Element) {
+	entry := el.Value.(*lruEntry)
+	c.ll.Remove(el)
+	delete(c.items, entry.key)
+	delete(c.tags[entry.tag], entry.key)
+	if len(c.tags[entry.tag]) == 0 {
+		delete(c.tags, entry.tag)
+	}
+}
@@ -0,0 +1,153 @@
+package gofmcon
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const singleRecordXML = `<fmresultset><error code="0"/>
+<metadata><field-definition name="name" result="text" max-repeat="1"/></metadata>
+<resultset count="1" fetch-size="1"><record record-id="1" mod-id="3"><field name="name"><data>John</data></field></record></resultset>
+</fmresultset>`
+
+func TestQueryCache(t *testing.T) {
+	var requests int32
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte(singleRecordXML))
+	})
+	conn.SetCache(&CacheConfig{Cache: NewLRUCache(10), TTL: time.Minute})
+	ctx := context.Background()
+
+	find := NewFMQuery("db", "people", FindAll)
+	rs, err := conn.Query(ctx, find)
+	assert.NoError(t, err)
+	rs.Resultset.Records[0].fieldsMap["name"] = "mutated"
+
+	rs, err = conn.Query(ctx, find)
+	assert.NoError(t, err)
+	assert.Equal(t, "John", rs.Resultset.Records[0].Field("name"))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&requests))
+
+	_, err = conn.Query(ContextWithCredentials(ctx, "other", "pass"), find)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&requests))
+
+	_, err = conn.Query(ctx, NewFMQuery("db", "people", New))
+	assert.NoError(t, err)
+	_, err = conn.Query(ctx, find)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, atomic.LoadInt32(&requests))
+}
+
+func TestQueryCacheCollapsesConcurrentRequests(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		_, _ = w.Write([]byte(singleRecordXML))
+	})
+	conn.SetCache(&CacheConfig{Cache: NewLRUCache(10), TTL: time.Minute})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := conn.Query(context.Background(), NewFMQuery("db", "people", FindAll))
+			assert.NoError(t, err)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.EqualValues(t, 1, atomic.LoadInt32(&requests))
+}
+
+func TestQueryCacheSkipsReadsOlderThanWrite(t *testing.T) {
+	var mu sync.Mutex
+	name := "John"
+	var reads int32
+	readStarted := make(chan struct{})
+	release := make(chan struct{})
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		q, err := ParseFMQuery(r.URL.RawQuery)
+		if err != nil {
+			t.Errorf("invalid request %s: %v", r.URL.RawQuery, err)
+			return
+		}
+		mu.Lock()
+		if q.Action == Edit {
+			name = q.QueryFields[0].Fields[0].Value
+		}
+		resp := strings.Replace(singleRecordXML, "John", name, 1)
+		mu.Unlock()
+
+		// the first read answers with the name before the edit after the edit is done
+		if q.Action == FindAll && atomic.AddInt32(&reads, 1) == 1 {
+			close(readStarted)
+			<-release
+		}
+		_, _ = w.Write([]byte(resp))
+	})
+	conn.SetCache(&CacheConfig{Cache: NewLRUCache(10), TTL: time.Minute})
+	ctx := context.Background()
+	find := NewFMQuery("db", "people", FindAll)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rs, err := conn.Query(ctx, find)
+		assert.NoError(t, err)
+		assert.Equal(t, "John", rs.Resultset.Records[0].Field("name"))
+	}()
+	<-readStarted
+
+	edit := NewFMQuery("db", "people", Edit).WithRecordID(1).WithFields(FMQueryField{Name: "name", Value: "Jane"})
+	_, err := conn.Query(ctx, edit)
+	assert.NoError(t, err)
+	close(release)
+	<-done
+
+	rs, err := conn.Query(ctx, find)
+	assert.NoError(t, err)
+	assert.Equal(t, "Jane", rs.Resultset.Records[0].Field("name"))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&reads))
+
+	// results of reads started after the write are cached
+	_, err = conn.Query(ctx, find)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&reads))
+}
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(2)
+	c.Set("a", "t1", FMResultset{Version: "a"}, time.Minute)
+	c.Set("b", "t1", FMResultset{Version: "b"}, time.Minute)
+	_, _ = c.Get("a")
+	c.Set("c", "t2", FMResultset{Version: "c"}, time.Minute)
+
+	_, ok := c.Get("b")
+	assert.False(t, ok, "least recently used entry must be evicted")
+	rs, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "a", rs.Version)
+
+	c.Invalidate("t1")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, c.Len())
+
+	c.Set("d", "t2", FMResultset{}, -time.Second)
+	_, ok = c.Get("d")
+	assert.False(t, ok, "expired entry must not be returned")
+}
@@ -0,0 +1,59 @@
+package gofmcon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+)
+
+// CanonicalQueryString is QueryString of the query with everything that
+// doesn't change the request put in a fixed order: fields of every find
+// and omit request, and fields of New and Edit, are sorted by name and value.
+// Queries that send the same request have the same canonical string,
+// however they were built
+func (q *FMQuery) CanonicalQueryString() string {
+	c := *q
+	c.QueryFields = q.canonicalFieldGroups()
+	return c.QueryString()
+}
+
+// Fingerprint is a hex encoded SHA-256 hash of CanonicalQueryString
+func (q *FMQuery) Fingerprint() string {
+	sum := sha256.Sum256([]byte(q.CanonicalQueryString()))
+	return hex.EncodeToString(sum[:])
+}
+
+func (q *FMQuery) canonicalFieldGroups() []FMQueryFieldGroup {
+	switch q.Action {
+	case New, Edit:
+		// fields of all groups are sent as name=value pairs
+		var fields []FMQueryField
+		for _, g := range q.QueryFields {
+			fields = append(fields, g.Fields...)
+		}
+		if len(fields) == 0 {
+			return nil
+		}
+		// the order of the same field given twice is kept
+		sort.SliceStable(fields, func(i, j int) bool {
+			return fields[i].Name < fields[j].Name
+		})
+		return []FMQueryFieldGroup{{Op: And, Fields: fields}}
+	}
+
+	groups := make([]FMQueryFieldGroup, len(q.QueryFields))
+	for i, g := range q.QueryFields {
+		fields := append([]FMQueryField(nil), g.Fields...)
+		// fields of Or are separate requests, their order is kept
+		if g.Op != Or {
+			sort.SliceStable(fields, func(i, j int) bool {
+				if fields[i].Name != fields[j].Name {
+					return fields[i].Name < fields[j].Name
+				}
+				return fields[i].valueWithOp() < fields[j].valueWithOp()
+			})
+		}
+		groups[i] = FMQueryFieldGroup{Op: g.Op, Fields: fields}
+	}
+	return groups
+}
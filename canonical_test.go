@@ -0,0 +1,50 @@
+package gofmcon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryStringParams(t *testing.T) {
+	q := NewFMQuery("db", "lay", FindAny).WithParam("-lop", "or").WithParam("-a b", "c&d")
+	assert.Equal(t, "-db=db&-lay=lay&-a+b=c%26d&-lop=or&-findany", q.QueryString())
+	assert.True(t, NewFMQuery("db", "lay", FindAll).WithParam("-lop", "or").isReadOnly())
+	assert.False(t, NewFMQuery("db", "lay", FindAll).WithParam("-script", "s").isReadOnly())
+
+	parsed, err := ParseFMQuery(q.QueryString())
+	assert.NoError(t, err)
+	assert.Equal(t, q.Query, parsed.Query)
+}
+
+func TestCanonicalQueryString(t *testing.T) {
+	a := NewFMQuery("db", "lay", Find).WithParam("-b", "2").WithParam("-a", "1").
+		WithFieldGroups(
+			FMQueryFieldGroup{Op: And, Fields: []FMQueryField{Eq("x").For("name"), Gt(1).For("age")}},
+			FMQueryFieldGroup{Op: Not, Fields: []FMQueryField{{Name: "status", Value: "b"}, {Name: "status", Value: "a"}}},
+		)
+	b := NewFMQuery("db", "lay", Find).WithParam("-a", "1").WithParam("-b", "2").
+		WithFieldGroups(
+			FMQueryFieldGroup{Op: And, Fields: []FMQueryField{Gt(1).For("age"), {Name: "name", Value: "==x", Raw: true}}},
+			FMQueryFieldGroup{Op: Not, Fields: []FMQueryField{{Name: "status", Value: "a"}, {Name: "status", Value: "b"}}},
+		)
+	assert.NotEqual(t, a.QueryString(), b.QueryString())
+	assert.Equal(t, a.CanonicalQueryString(), b.CanonicalQueryString())
+	assert.Equal(t, a.Fingerprint(), b.Fingerprint())
+	assert.Len(t, a.Fingerprint(), 64)
+
+	// the order of requests changes the found set
+	c := NewFMQuery("db", "lay", Find).WithFieldGroups(a.QueryFields[1], a.QueryFields[0])
+	assert.NotEqual(t, a.Fingerprint(), c.Fingerprint())
+
+	// canonical string doesn't modify the query
+	assert.Equal(t, "name", a.QueryFields[0].Fields[0].Name)
+
+	edit1 := NewFMQuery("db", "lay", Edit).WithRecordID(1).
+		WithFields(FMQueryField{Name: "b", Value: "2"}).
+		WithFields(FMQueryField{Name: "a", Value: "1"})
+	edit2 := NewFMQuery("db", "lay", Edit).WithRecordID(1).
+		WithFields(FMQueryField{Name: "a", Value: "1"}, FMQueryField{Name: "b", Value: "2"})
+	assert.Equal(t, "-db=db&-lay=lay&-recid=1&a=1&b=2&-edit", edit1.CanonicalQueryString())
+	assert.Equal(t, edit1.Fingerprint(), edit2.Fingerprint())
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/amanbolat/gofmcon"
+)
+
+func runDatabases(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("fmcli dbs", flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	names, err := c.fmc.DatabaseNames(ctx)
+	if err != nil {
+		return err
+	}
+	return writeNames(c.stdout, c.format, "database", names)
+}
+
+func runLayouts(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("layouts")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if c.database == "" {
+		return errors.New("-db is required")
+	}
+
+	names, err := c.fmc.LayoutNames(ctx, c.database)
+	if err != nil {
+		return err
+	}
+	return writeNames(c.stdout, c.format, "layout", names)
+}
+
+func runScripts(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("scripts")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if c.database == "" {
+		return errors.New("-db is required")
+	}
+
+	names, err := c.fmc.ScriptNames(ctx, c.database)
+	if err != nil {
+		return err
+	}
+	return writeNames(c.stdout, c.format, "script", names)
+}
+
+func runFind(ctx context.Context, c *cli, args []string) error {
+	var (
+		layout, filter string
+		fields         fieldsFlag
+		sortFields     sortFlag
+		max, skip      int
+	)
+	fs := c.flagSet("find")
+	fs.StringVar(&layout, "layout", "", "layout name")
+	fs.StringVar(&filter, "filter", "", `filter, e.g. 'status = "open" and total > 100'`)
+	fs.Var(&fields, "f", "field equal to the value, name=value, can be repeated")
+	fs.Var(&sortFields, "sort", "sort field, name or name:desc, can be repeated")
+	fs.IntVar(&max, "max", -1, "maximum number of records, -1 for all")
+	fs.IntVar(&skip, "skip", 0, "number of records to skip")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := c.requireLayout(layout); err != nil {
+		return err
+	}
+
+	action := gofmcon.FindAll
+	if filter != "" || len(fields) > 0 {
+		action = gofmcon.Find
+	}
+	q := gofmcon.NewFMQuery(c.database, layout, action).
+		WithSortFields(sortFields...).
+		Max(max).
+		Skip(skip)
+	// records must match the filter and all the fields
+	var exprs []gofmcon.Expr
+	if filter != "" {
+		e, err := gofmcon.ParseFilter(filter)
+		if err != nil {
+			return err
+		}
+		exprs = append(exprs, e)
+	}
+	for _, f := range fields {
+		f.Op = gofmcon.Equal
+		exprs = append(exprs, gofmcon.QueryFieldExpr(f))
+	}
+	if len(exprs) > 0 {
+		q.WithExpr(gofmcon.AndExpr(exprs...))
+	}
+
+	rs, err := c.fmc.Query(ctx, q)
+	if errors.Is(err, gofmcon.ErrRecordNotFound) {
+		return writeRecords(c.stdout, c.format, nil)
+	}
+	if err != nil {
+		return err
+	}
+	return c.writeResultset(rs)
+}
+
+func runGet(ctx context.Context, c *cli, args []string) error {
+	var layout string
+	var recID int
+	fs := c.flagSet("get")
+	fs.StringVar(&layout, "layout", "", "layout name")
+	fs.IntVar(&recID, "recid", 0, "record id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := c.requireRecord(layout, recID); err != nil {
+		return err
+	}
+
+	return c.query(ctx, gofmcon.NewFMQuery(c.database, layout, gofmcon.Find).WithRecordID(recID))
+}
+
+func runCreate(ctx context.Context, c *cli, args []string) error {
+	var layout string
+	var fields fieldsFlag
+	fs := c.flagSet("create")
+	fs.StringVar(&layout, "layout", "", "layout name")
+	fs.Var(&fields, "f", "field value, name=value, can be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := c.requireLayout(layout); err != nil {
+		return err
+	}
+
+	return c.query(ctx, gofmcon.NewFMQuery(c.database, layout, gofmcon.New).WithFields(fields...))
+}
+
+func runEdit(ctx context.Context, c *cli, args []string) error {
+	var layout string
+	var recID, modID int
+	var fields fieldsFlag
+	fs := c.flagSet("edit")
+	fs.StringVar(&layout, "layout", "", "layout name")
+	fs.IntVar(&recID, "recid", 0, "record id")
+	fs.IntVar(&modID, "modid", 0, "modification id the record must have, 0 to edit it anyway")
+	fs.Var(&fields, "f", "field value, name=value, can be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := c.requireRecord(layout, recID); err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return errors.New("at least one -f is required")
+	}
+
+	q := gofmcon.NewFMQuery(c.database, layout, gofmcon.Edit).
+		WithRecordID(recID).
+		WithModID(modID).
+		WithFields(fields...)
+	return c.query(ctx, q)
+}
+
+func runDelete(ctx context.Context, c *cli, args []string) error {
+	var layout string
+	var recID int
+	fs := c.flagSet("delete")
+	fs.StringVar(&layout, "layout", "", "layout name")
+	fs.IntVar(&recID, "recid", 0, "record id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := c.requireRecord(layout, recID); err != nil {
+		return err
+	}
+
+	_, err := c.fmc.Query(ctx, gofmcon.NewFMQuery(c.database, layout, gofmcon.Delete).WithRecordID(recID))
+	return err
+}
+
+func runDuplicate(ctx context.Context, c *cli, args []string) error {
+	var layout string
+	var recID int
+	fs := c.flagSet("dup")
+	fs.StringVar(&layout, "layout", "", "layout name")
+	fs.IntVar(&recID, "recid", 0, "record id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := c.requireRecord(layout, recID); err != nil {
+		return err
+	}
+
+	return c.query(ctx, gofmcon.NewFMQuery(c.database, layout, gofmcon.Duplicate).WithRecordID(recID))
+}
+
+func runScript(ctx context.Context, c *cli, args []string) error {
+	var layout, name, param string
+	var opts gofmcon.ScriptOptions
+	fs := c.flagSet("script")
+	fs.StringVar(&layout, "layout", "", "layout name")
+	fs.StringVar(&name, "name", "", "script name")
+	fs.StringVar(&param, "param", "", "script parameter")
+	fs.StringVar(&opts.ResultField, "result-field", "", "field the script writes its result to, printed instead of the record")
+	fs.StringVar(&opts.ErrorField, "error-field", "", "field the script writes its error code to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := c.requireLayout(layout); err != nil {
+		return err
+	}
+	if name == "" {
+		return errors.New("-name is required")
+	}
+
+	res, err := c.fmc.RunScriptWithOptions(ctx, c.database, layout, name, param, opts)
+	if err != nil {
+		return err
+	}
+	if opts.ResultField != "" {
+		_, err = fmt.Fprintln(c.stdout, res.Result)
+		return err
+	}
+	return c.writeResultset(res.Resultset)
+}
+
+// query sends the query and prints the records of the response
+func (c *cli) query(ctx context.Context, q *gofmcon.FMQuery) error {
+	rs, err := c.fmc.Query(ctx, q)
+	if err != nil {
+		return err
+	}
+	return c.writeResultset(rs)
+}
+
+func (c *cli) writeResultset(rs gofmcon.FMResultset) error {
+	var records []*gofmcon.Record
+	if rs.Resultset != nil {
+		records = rs.Resultset.Records
+	}
+	return writeRecords(c.stdout, c.format, records)
+}
+
+func (c *cli) requireLayout(layout string) error {
+	if c.database == "" || layout == "" {
+		return errors.New("-db and -layout are required")
+	}
+	return nil
+}
+
+func (c *cli) requireRecord(layout string, recID int) error {
+	if err := c.requireLayout(layout); err != nil {
+		return err
+	}
+	if recID <= 0 {
+		return errors.New("-recid is required")
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+// Command fmcli sends ad-hoc queries to FileMaker server over XML Web Publishing
+// and prints the records as a table, JSON, NDJSON or CSV.
+//
+//	fmcli -host fm.example.com dbs
+//	fmcli -host fm.example.com layouts -db sales
+//	fmcli -host fm.example.com -format json find -db sales -layout customers -filter 'status = "open"' -sort name -max 10
+//	fmcli -host fm.example.com edit -db sales -layout customers -recid 42 -modid 7 -f status=closed
+//	fmcli -host fm.example.com repl -db sales
+//
+// The account is read from FM_USER and FM_PASS or, when FM_USER is not set,
+// from the config file given with -config or FMCLI_CONFIG, e.g.
+//
+//	{"host": "fm.example.com", "username": "admin", "password": "secret", "database": "sales"}
+//
+// Flags take precedence over environment variables, which take precedence over the config file
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/amanbolat/gofmcon"
+)
+
+// config is the content of the config file
+type config struct {
+	Host     string `json:"host"`
+	Port     string `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+type options struct {
+	host, port string
+	configPath string
+	format     string
+	timeout    time.Duration
+}
+
+// cli runs a single command
+type cli struct {
+	fmc      *gofmcon.FMConnector
+	database string
+	format   string
+	timeout  time.Duration
+	stdin    io.Reader
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, c *cli, args []string) error
+}
+
+var commands = map[string]command{
+	"dbs":     {"list databases", runDatabases},
+	"layouts": {"list layouts of the database", runLayouts},
+	"scripts": {"list scripts of the database", runScripts},
+	"find":    {"find records", runFind},
+	"get":     {"get the record by record id", runGet},
+	"create":  {"create a record", runCreate},
+	"edit":    {"edit the record", runEdit},
+	"delete":  {"delete the record", runDelete},
+	"dup":     {"duplicate the record", runDuplicate},
+	"script":  {"run a script", runScript},
+	"repl":    {"start an interactive shell", runREPL},
+}
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fmcli:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var opts options
+	fs := flag.NewFlagSet("fmcli", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.host, "host", os.Getenv("FM_HOST"), "FileMaker server host, defaults to FM_HOST")
+	fs.StringVar(&opts.port, "port", os.Getenv("FM_PORT"), "FileMaker server port, defaults to FM_PORT")
+	fs.StringVar(&opts.configPath, "config", os.Getenv("FMCLI_CONFIG"), "config file, defaults to FMCLI_CONFIG")
+	fs.StringVar(&opts.format, "format", formatTable, "output format: table, json, ndjson or csv")
+	fs.DurationVar(&opts.timeout, "timeout", time.Minute, "timeout of the command, or of every command of repl")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: fmcli [flags] command [command flags]")
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr, "\ncommands:")
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(stderr, "  %-8s %s\n", name, commands[name].usage)
+		}
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unknown command %q", fs.Arg(0))
+	}
+	if !isFormat(opts.format) {
+		return fmt.Errorf("unknown format %q", opts.format)
+	}
+
+	c, err := newCLI(opts, stdin, stdout, stderr)
+	if err != nil {
+		return err
+	}
+
+	if fs.Arg(0) != "repl" {
+		// the shell applies the timeout to every command instead
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	return cmd.run(ctx, c, fs.Args()[1:])
+}
+
+func newCLI(opts options, stdin io.Reader, stdout, stderr io.Writer) (*cli, error) {
+	var cfg config
+	if opts.configPath != "" {
+		data, err := os.ReadFile(opts.configPath)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(data, &cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", opts.configPath, err)
+		}
+	}
+
+	host, port := opts.host, opts.port
+	if host == "" {
+		host = cfg.Host
+	}
+	if port == "" {
+		port = cfg.Port
+	}
+	if host == "" {
+		return nil, errors.New("-host is required")
+	}
+
+	fmc := gofmcon.NewFMConnector(host, port, "", "")
+	if _, ok := os.LookupEnv(gofmcon.EnvUsername); !ok && cfg.Username != "" {
+		fmc.SetCredentialsProvider(gofmcon.StaticCredentials{Username: cfg.Username, Password: cfg.Password})
+	} else {
+		fmc.SetCredentialsProvider(gofmcon.EnvCredentials{})
+	}
+
+	database := os.Getenv("FM_DB")
+	if database == "" {
+		database = cfg.Database
+	}
+
+	return &cli{fmc: fmc, database: database, format: opts.format, timeout: opts.timeout, stdin: stdin, stdout: stdout, stderr: stderr}, nil
+}
+
+// flagSet creates the flag set of the command with -db
+// defaulting to FM_DB or the database of the config file
+func (c *cli) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("fmcli "+name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.StringVar(&c.database, "db", c.database, "database name, defaults to FM_DB")
+	return fs
+}
+
+// fieldsFlag collects repeated -f name=value flags
+type fieldsFlag []gofmcon.FMQueryField
+
+func (f *fieldsFlag) String() string {
+	var s []string
+	for _, field := range *f {
+		s = append(s, field.Name+"="+field.Value)
+	}
+	return strings.Join(s, " ")
+}
+
+func (f *fieldsFlag) Set(v string) error {
+	i := strings.Index(v, "=")
+	if i < 1 {
+		return fmt.Errorf("field %q is not name=value", v)
+	}
+	*f = append(*f, gofmcon.FMQueryField{Name: v[:i], Value: v[i+1:]})
+	return nil
+}
+
+// sortFlag collects repeated -sort name or -sort name:desc flags
+type sortFlag []gofmcon.FMSortField
+
+func (f *sortFlag) String() string {
+	var s []string
+	for _, field := range *f {
+		s = append(s, field.Name+":"+field.Order.String())
+	}
+	return strings.Join(s, " ")
+}
+
+func (f *sortFlag) Set(v string) error {
+	field := gofmcon.FMSortField{Name: v, Order: gofmcon.Ascending}
+	if i := strings.LastIndex(v, ":"); i > 0 {
+		switch strings.ToLower(v[i+1:]) {
+		case "asc", "ascend":
+			field.Name = v[:i]
+		case "desc", "descend":
+			field.Name, field.Order = v[:i], gofmcon.Descending
+		}
+	}
+	*f = append(*f, field)
+	return nil
+}
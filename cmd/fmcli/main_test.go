@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/amanbolat/gofmcon"
+)
+
+const customersXML = `<fmresultset><error code="0"/>
+<metadata>
+<field-definition name="name" result="text" max-repeat="1"/>
+<field-definition name="phone" result="text" max-repeat="2"/>
+<field-definition name="total" result="number" max-repeat="1"/>
+</metadata>
+<resultset count="2" fetch-size="2">
+<record record-id="1" mod-id="3"><field name="name"><data>Jane</data></field><field name="phone"><data>1</data><data>2</data></field><field name="total"><data>10</data></field></record>
+<record record-id="2" mod-id="1"><field name="name"><data>John, Jr.</data></field><field name="phone"><data></data><data></data></field><field name="total"><data>2.5</data></field></record>
+</resultset>
+</fmresultset>`
+
+// testServer answers every request with the response and
+// records the requests it received
+type testServer struct {
+	host, port string
+	response   string
+	// respond, if set, answers instead of response
+	respond func(rawQuery string) string
+	queries []string
+	users   []string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	ts := &testServer{response: customersXML}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		ts.users = append(ts.users, user)
+		ts.queries = append(ts.queries, r.URL.RawQuery)
+		if ts.respond != nil {
+			_, _ = w.Write([]byte(ts.respond(r.URL.RawQuery)))
+			return
+		}
+		_, _ = w.Write([]byte(ts.response))
+	}))
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.host, ts.port = u.Hostname(), u.Port()
+	return ts
+}
+
+func (ts *testServer) run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), append([]string{"-host", ts.host, "-port", ts.port}, args...), nil, &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func (ts *testServer) lastQuery(t *testing.T) *gofmcon.FMQuery {
+	t.Helper()
+	q, err := gofmcon.ParseFMQuery(ts.queries[len(ts.queries)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func TestFind(t *testing.T) {
+	t.Setenv("FM_USER", "user")
+	ts := newTestServer(t)
+
+	out, err := ts.run(t, "find", "-db", "sales", "-layout", "customers", "-filter", `name = "Jane" or total > 5`, "-sort", "name:desc", "-sort", "total", "-max", "10")
+	assert.NoError(t, err)
+	assert.Equal(t, ""+
+		"recid  modid  name       phone  total\n"+
+		"1      3      Jane       1, 2   10\n"+
+		"2      1      John, Jr.  ,      2.5\n", out)
+
+	q := ts.lastQuery(t)
+	assert.Equal(t, gofmcon.Find, q.Action)
+	assert.Equal(t, `name = "Jane" OR total > "5"`, q.FilterString())
+	assert.Equal(t, []gofmcon.FMSortField{
+		{Name: "name", Order: gofmcon.Descending},
+		{Name: "total", Order: gofmcon.Ascending},
+	}, q.SortFields)
+	assert.Equal(t, 10, q.MaxRecords)
+
+	out, err = ts.run(t, "-format", "csv", "find", "-db", "sales", "-layout", "customers")
+	assert.NoError(t, err)
+	assert.Equal(t, "recid,modid,name,phone,total\n1,3,Jane,\"1\n2\",10\n2,1,\"John, Jr.\",\"\n\",2.5\n", out)
+	assert.Equal(t, gofmcon.FindAll, ts.lastQuery(t).Action)
+
+	out, err = ts.run(t, "-format", "ndjson", "find", "-db", "sales", "-layout", "customers", "-f", "name=Jane")
+	assert.NoError(t, err)
+	assert.Equal(t, ""+
+		`{"recordId":1,"modId":3,"fields":{"name":"Jane","phone":["1","2"],"total":10}}`+"\n"+
+		`{"recordId":2,"modId":1,"fields":{"name":"John, Jr.","phone":["",""],"total":2.5}}`+"\n", out)
+	assert.Equal(t, []gofmcon.FMQueryField{{Name: "name", Value: "Jane", Op: gofmcon.Equal}}, ts.lastQuery(t).QueryFields[0].Fields)
+
+	// the fields narrow the filter down
+	_, err = ts.run(t, "find", "-db", "sales", "-layout", "customers", "-filter", `name = "Jane" or total > 5`, "-f", "phone=1")
+	assert.NoError(t, err)
+	assert.Equal(t, `name = "Jane" AND phone = "1" OR total > "5" AND phone = "1"`, ts.lastQuery(t).FilterString())
+	raw, err := url.ParseQuery(ts.queries[len(ts.queries)-1])
+	assert.NoError(t, err)
+	assert.Equal(t, "(q1,q2);(q3,q4)", raw.Get("-query"))
+
+	ts.response = `<fmresultset><error code="401"/></fmresultset>`
+	out, err = ts.run(t, "-format", "json", "find", "-db", "sales", "-layout", "customers", "-f", "name=Nobody")
+	assert.NoError(t, err)
+	assert.Equal(t, "[]\n", out)
+}
+
+func TestRecordCommands(t *testing.T) {
+	t.Setenv("FM_USER", "user")
+	ts := newTestServer(t)
+
+	_, err := ts.run(t, "get", "-db", "sales", "-layout", "customers", "-recid", "1")
+	assert.NoError(t, err)
+	q := ts.lastQuery(t)
+	assert.Equal(t, gofmcon.Find, q.Action)
+	assert.Equal(t, 1, q.RecordID)
+
+	_, err = ts.run(t, "create", "-db", "sales", "-layout", "customers", "-f", "name=Jane", "-f", "phone(2)=a=b")
+	assert.NoError(t, err)
+	q = ts.lastQuery(t)
+	assert.Equal(t, gofmcon.New, q.Action)
+	assert.Equal(t, []gofmcon.FMQueryField{{Name: "name", Value: "Jane"}, {Name: "phone(2)", Value: "a=b"}}, q.QueryFields[0].Fields)
+
+	_, err = ts.run(t, "edit", "-db", "sales", "-layout", "customers", "-recid", "1", "-modid", "3", "-f", "name=Jo")
+	assert.NoError(t, err)
+	q = ts.lastQuery(t)
+	assert.Equal(t, gofmcon.Edit, q.Action)
+	assert.Equal(t, 3, q.ModID)
+
+	_, err = ts.run(t, "dup", "-db", "sales", "-layout", "customers", "-recid", "2")
+	assert.NoError(t, err)
+	assert.Equal(t, gofmcon.Duplicate, ts.lastQuery(t).Action)
+
+	ts.response = `<fmresultset><error code="0"/></fmresultset>`
+	out, err := ts.run(t, "delete", "-db", "sales", "-layout", "customers", "-recid", "2")
+	assert.NoError(t, err)
+	assert.Equal(t, "", out)
+	assert.Equal(t, gofmcon.Delete, ts.lastQuery(t).Action)
+
+	ts.response = `<fmresultset><error code="306"/></fmresultset>`
+	_, err = ts.run(t, "edit", "-db", "sales", "-layout", "customers", "-recid", "1", "-modid", "2", "-f", "name=Jo")
+	assert.Error(t, err)
+
+	ts.response = customersXML
+	out, err = ts.run(t, "script", "-db", "sales", "-layout", "customers", "-name", "total", "-param", "42", "-result-field", "total")
+	assert.NoError(t, err)
+	assert.Equal(t, "10\n", out)
+	assert.Equal(t, "total", ts.lastQuery(t).PostFindScript)
+
+	requests := len(ts.queries)
+	for _, args := range [][]string{
+		{"get", "-db", "sales", "-layout", "customers"},
+		{"edit", "-db", "sales", "-layout", "customers", "-recid", "1"},
+		{"find", "-layout", "customers"},
+		{"script", "-db", "sales", "-layout", "customers"},
+		{"create", "-db", "sales", "-layout", "customers", "-f", "name"},
+		{"-format", "xml", "dbs"},
+		{"undo"},
+	} {
+		_, err = ts.run(t, args...)
+		assert.Error(t, err, args)
+	}
+	assert.Len(t, ts.queries, requests)
+}
+
+func TestNames(t *testing.T) {
+	t.Setenv("FM_USER", "user")
+	ts := newTestServer(t)
+	ts.response = `<fmresultset><error code="0"/><resultset count="2" fetch-size="2">` +
+		`<record record-id="0" mod-id="0"><field name="LAYOUT_NAME"><data>customers</data></field></record>` +
+		`<record record-id="0" mod-id="0"><field name="LAYOUT_NAME"><data>orders</data></field></record>` +
+		`</resultset></fmresultset>`
+
+	out, err := ts.run(t, "layouts", "-db", "sales")
+	assert.NoError(t, err)
+	assert.Equal(t, "customers\norders\n", out)
+	assert.Equal(t, "-db=sales&-layoutnames", ts.queries[0])
+
+	out, err = ts.run(t, "-format", "csv", "scripts", "-db", "sales")
+	assert.NoError(t, err)
+	assert.Equal(t, "script\ncustomers\norders\n", out)
+
+	out, err = ts.run(t, "-format", "json", "dbs")
+	assert.NoError(t, err)
+	assert.Equal(t, "[\n  \"customers\",\n  \"orders\"\n]\n", out)
+	assert.Equal(t, "-dbnames", ts.queries[2])
+
+	_, err = ts.run(t, "layouts")
+	assert.Error(t, err)
+}
+
+func TestConfig(t *testing.T) {
+	ts := newTestServer(t)
+	path := filepath.Join(t.TempDir(), "fmcli.json")
+	err := os.WriteFile(path, []byte(`{"host": "`+ts.host+`", "port": "`+ts.port+`", "username": "admin", "password": "secret", "database": "sales"}`), 0o600)
+	assert.NoError(t, err)
+
+	var stdout, stderr bytes.Buffer
+	err = run(context.Background(), []string{"-config", path, "find", "-layout", "customers"}, nil, &stdout, &stderr)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin"}, ts.users)
+	assert.Equal(t, "sales", ts.lastQuery(t).Database)
+
+	t.Setenv("FM_USER", "user")
+	t.Setenv("FM_DB", "stock")
+	err = run(context.Background(), []string{"-config", path, "find", "-layout", "customers"}, nil, &stdout, &stderr)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin", "user"}, ts.users)
+	assert.Equal(t, "stock", ts.lastQuery(t).Database)
+
+	err = run(context.Background(), []string{"-config", filepath.Join(t.TempDir(), "missing.json"), "dbs"}, nil, &stdout, &stderr)
+	assert.Error(t, err)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/amanbolat/gofmcon"
+)
+
+const (
+	formatTable  = "table"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+)
+
+func isFormat(format string) bool {
+	switch format {
+	case formatTable, formatJSON, formatNDJSON, formatCSV:
+		return true
+	}
+	return false
+}
+
+// jsonRecord is a record printed as JSON, fields are
+// the same as returned by Record.JSONFields
+type jsonRecord struct {
+	RecordID int             `json:"recordId"`
+	ModID    int             `json:"modId"`
+	Fields   json.RawMessage `json:"fields"`
+}
+
+// writeRecords prints the records, table and CSV have a column for every
+// field of the records with repetitions joined, related sets are only
+// printed as JSON
+func writeRecords(w io.Writer, format string, records []*gofmcon.Record) error {
+	switch format {
+	case formatJSON, formatNDJSON:
+		values := make([]jsonRecord, 0, len(records))
+		for _, rec := range records {
+			fields, err := rec.JSONFields()
+			if err != nil {
+				return err
+			}
+			values = append(values, jsonRecord{RecordID: rec.ID, ModID: rec.ModID, Fields: fields})
+		}
+		if format == formatJSON {
+			return writeJSON(w, values)
+		}
+		return writeNDJSON(w, values)
+	}
+
+	columns := recordColumns(records)
+	header := append([]string{"recid", "modid"}, columns...)
+	sep := ", "
+	if format == formatCSV {
+		sep = "\n"
+	}
+	var rows [][]string
+	for _, rec := range records {
+		row := []string{strconv.Itoa(rec.ID), strconv.Itoa(rec.ModID)}
+		for _, name := range columns {
+			row = append(row, recordValue(rec, name, sep))
+		}
+		rows = append(rows, row)
+	}
+
+	if format == formatCSV {
+		return writeCSV(w, header, rows)
+	}
+	return writeTable(w, header, rows)
+}
+
+// writeNames prints names returned by dbs, layouts and scripts
+func writeNames(w io.Writer, format, column string, names []string) error {
+	switch format {
+	case formatJSON:
+		return writeJSON(w, names)
+	case formatNDJSON:
+		return writeNDJSON(w, names)
+	case formatCSV:
+		var rows [][]string
+		for _, name := range names {
+			rows = append(rows, []string{name})
+		}
+		return writeCSV(w, []string{column}, rows)
+	}
+	for _, name := range names {
+		if _, err := fmt.Fprintln(w, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordColumns returns names of the fields in the order they first occur
+func recordColumns(records []*gofmcon.Record) []string {
+	var columns []string
+	seen := map[string]bool{}
+	for _, rec := range records {
+		for _, f := range rec.Fields {
+			if !seen[f.Name] {
+				seen[f.Name] = true
+				columns = append(columns, f.Name)
+			}
+		}
+	}
+	return columns
+}
+
+func recordValue(rec *gofmcon.Record, name, sep string) string {
+	for _, f := range rec.Fields {
+		if f.Name == name {
+			return strings.Join(f.Data, sep)
+		}
+	}
+	return ""
+}
+
+func writeJSON[T any](w io.Writer, values []T) error {
+	b, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+func writeNDJSON[T any](w io.Writer, values []T) error {
+	enc := json.NewEncoder(w)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCSV(w io.Writer, header []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func writeTable(w io.Writer, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
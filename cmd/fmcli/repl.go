@@ -0,0 +1,502 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/peterh/liner"
+
+	"github.com/amanbolat/gofmcon"
+)
+
+const replHelp = `commands:
+  dbs                    list databases
+  layouts                list layouts of the database
+  scripts                list scripts of the database
+  use db <name>          use the database
+  use layout <name>      use the layout of the database
+  fields                 list fields of the layout
+  find [filter]          find records, e.g. find status = "open" AND total > 100,
+                         all records without a filter
+  sort [field[:desc]...] sort the next finds by the fields separated with commas
+  next, prev             show the next or the previous page of the found records
+  show <recid>           show the record with its related sets
+  format <format>        print records as table, json, ndjson or csv
+  help                   show this help
+  exit                   leave the shell
+
+field names are completed with tab`
+
+// shell is the interactive mode of fmcli. It keeps the database and the
+// layout in use, the fields of the layout for completion and the last find
+// for paging
+type shell struct {
+	*cli
+	pageSize int
+
+	layout     string
+	fields     gofmcon.FieldsDefinitions
+	sortFields []gofmcon.FMSortField
+
+	// query is the last find, records are the page of it at skip
+	query   *gofmcon.FMQuery
+	skip    int
+	total   int
+	records []*gofmcon.Record
+
+	// names are database and layout names cached for completion
+	names map[string][]string
+}
+
+// lineReader reads commands, it's liner.State on a terminal
+type lineReader interface {
+	Prompt(prompt string) (string, error)
+	AppendHistory(item string)
+	Close() error
+}
+
+// scanReader reads commands from input other than a terminal, e.g. a pipe
+type scanReader struct {
+	s *bufio.Scanner
+}
+
+func (r *scanReader) Prompt(prompt string) (string, error) {
+	if r.s.Scan() {
+		return r.s.Text(), nil
+	}
+	if err := r.s.Err(); err != nil {
+		return "", err
+	}
+	return "", io.EOF
+}
+
+func (r *scanReader) AppendHistory(item string) {}
+
+func (r *scanReader) Close() error {
+	return nil
+}
+
+func runREPL(ctx context.Context, c *cli, args []string) error {
+	var layout string
+	sh := &shell{cli: c, names: map[string][]string{}}
+	fs := c.flagSet("repl")
+	fs.StringVar(&layout, "layout", "", "layout name")
+	fs.IntVar(&sh.pageSize, "page", 20, "number of records on a page")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if sh.pageSize < 1 {
+		return errors.New("-page must be positive")
+	}
+
+	if layout != "" {
+		if err := sh.exec(ctx, "use layout "+layout); err != nil {
+			return err
+		}
+	}
+
+	r := sh.lineReader()
+	defer r.Close()
+	for {
+		line, err := r.Prompt(sh.prompt())
+		if errors.Is(err, liner.ErrPromptAborted) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		r.AppendHistory(line)
+		if line == "exit" || line == "quit" {
+			return nil
+		}
+
+		err = sh.exec(ctx, line)
+		if err != nil {
+			fmt.Fprintln(sh.stderr, "error:", err)
+		}
+	}
+}
+
+func (sh *shell) lineReader() lineReader {
+	if f, ok := sh.stdin.(*os.File); ok && f == os.Stdin && liner.TerminalSupported() {
+		l := liner.NewLiner()
+		l.SetCtrlCAborts(true)
+		l.SetTabCompletionStyle(liner.TabPrints)
+		l.SetCompleter(sh.complete)
+		return l
+	}
+	return &scanReader{s: bufio.NewScanner(sh.stdin)}
+}
+
+func (sh *shell) prompt() string {
+	switch {
+	case sh.layout != "":
+		return sh.database + "/" + sh.layout + "> "
+	case sh.database != "":
+		return sh.database + "> "
+	}
+	return "fmcli> "
+}
+
+// exec runs a command of the shell
+func (sh *shell) exec(ctx context.Context, line string) error {
+	ctx, cancel := context.WithTimeout(ctx, sh.timeout)
+	defer cancel()
+
+	name, arg := splitCommand(line)
+	switch name {
+	case "help":
+		_, err := fmt.Fprintln(sh.stdout, replHelp)
+		return err
+	case "dbs":
+		names, err := sh.fmc.DatabaseNames(ctx)
+		if err != nil {
+			return err
+		}
+		sh.names[""] = names
+		return writeNames(sh.stdout, sh.format, "database", names)
+	case "layouts":
+		if sh.database == "" {
+			return errors.New("no database, use db <name> first")
+		}
+		names, err := sh.fmc.LayoutNames(ctx, sh.database)
+		if err != nil {
+			return err
+		}
+		sh.names[sh.database] = names
+		return writeNames(sh.stdout, sh.format, "layout", names)
+	case "scripts":
+		if sh.database == "" {
+			return errors.New("no database, use db <name> first")
+		}
+		names, err := sh.fmc.ScriptNames(ctx, sh.database)
+		if err != nil {
+			return err
+		}
+		return writeNames(sh.stdout, sh.format, "script", names)
+	case "use":
+		return sh.use(ctx, arg)
+	case "fields":
+		if sh.layout == "" {
+			return errors.New("no layout, use layout <name> first")
+		}
+		return sh.writeFields()
+	case "find":
+		return sh.find(ctx, arg)
+	case "sort":
+		return sh.sort(arg)
+	case "next":
+		if sh.query == nil {
+			return errors.New("nothing found yet")
+		}
+		if sh.skip+sh.pageSize >= sh.total {
+			return errors.New("already on the last page")
+		}
+		sh.skip += sh.pageSize
+		return sh.fetch(ctx)
+	case "prev":
+		if sh.query == nil {
+			return errors.New("nothing found yet")
+		}
+		if sh.skip == 0 {
+			return errors.New("already on the first page")
+		}
+		sh.skip -= sh.pageSize
+		if sh.skip < 0 {
+			sh.skip = 0
+		}
+		return sh.fetch(ctx)
+	case "show":
+		return sh.show(ctx, arg)
+	case "format":
+		if !isFormat(arg) {
+			return fmt.Errorf("unknown format %q", arg)
+		}
+		sh.format = arg
+		return nil
+	}
+	return fmt.Errorf("unknown command %q, see help", name)
+}
+
+func splitCommand(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	i := strings.IndexAny(line, " \t")
+	if i < 0 {
+		return line, ""
+	}
+	return line[:i], strings.TrimSpace(line[i+1:])
+}
+
+func (sh *shell) use(ctx context.Context, arg string) error {
+	kind, name := splitCommand(arg)
+	if name == "" {
+		return errors.New("use db <name> or use layout <name>")
+	}
+
+	switch kind {
+	case "db":
+		sh.database = name
+		sh.layout = ""
+		sh.fields = nil
+		sh.reset()
+		return nil
+	case "layout":
+		if sh.database == "" {
+			return errors.New("no database, use db <name> first")
+		}
+		fields, err := sh.fmc.LayoutFields(ctx, sh.database, name)
+		if err != nil {
+			return err
+		}
+		sh.layout = name
+		sh.fields = fields
+		sh.reset()
+		return nil
+	}
+	return errors.New("use db <name> or use layout <name>")
+}
+
+// reset forgets the last find
+func (sh *shell) reset() {
+	sh.query = nil
+	sh.skip = 0
+	sh.total = 0
+	sh.records = nil
+	sh.sortFields = nil
+}
+
+func (sh *shell) writeFields() error {
+	tw := tabwriter.NewWriter(sh.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "field\ttype\trepetitions\toptions")
+	for _, fd := range sh.fields {
+		var opts []string
+		if fd.Kind != "" && fd.Kind != gofmcon.KindNormal {
+			opts = append(opts, string(fd.Kind))
+		}
+		if fd.Global {
+			opts = append(opts, "global")
+		}
+		if fd.NotEmpty {
+			opts = append(opts, "not empty")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", fd.Name, fd.Type, fd.MaxRepeat, strings.Join(opts, ", "))
+	}
+	return tw.Flush()
+}
+
+func (sh *shell) find(ctx context.Context, filter string) error {
+	if sh.layout == "" {
+		return errors.New("no layout, use layout <name> first")
+	}
+
+	action := gofmcon.FindAll
+	if filter != "" {
+		action = gofmcon.Find
+	}
+	q := gofmcon.NewFMQuery(sh.database, sh.layout, action).
+		WithSortFields(sh.sortFields...).
+		Max(sh.pageSize)
+	if filter != "" {
+		q.WithFilter(filter)
+	}
+	if err := q.Err(); err != nil {
+		return err
+	}
+
+	sh.query = q
+	sh.skip = 0
+	return sh.fetch(ctx)
+}
+
+func (sh *shell) sort(arg string) error {
+	var fields sortFlag
+	for _, s := range strings.Split(arg, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if err := fields.Set(s); err != nil {
+			return err
+		}
+	}
+	sh.sortFields = fields
+	return nil
+}
+
+// fetch fetches and prints the page of the last find at skip
+func (sh *shell) fetch(ctx context.Context) error {
+	rs, err := sh.fmc.Query(ctx, sh.query.Clone().Skip(sh.skip))
+	if errors.Is(err, gofmcon.ErrRecordNotFound) {
+		sh.total, sh.records = 0, nil
+		_, err = fmt.Fprintln(sh.stdout, "no records found")
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	sh.total, sh.records = 0, nil
+	if rs.Resultset != nil {
+		sh.total, sh.records = rs.Resultset.Count, rs.Resultset.Records
+	}
+	if err := writeRecords(sh.stdout, sh.format, sh.records); err != nil {
+		return err
+	}
+	if len(sh.records) == 0 {
+		return nil
+	}
+	_, err = fmt.Fprintf(sh.stderr, "records %d-%d of %d\n", sh.skip+1, sh.skip+len(sh.records), sh.total)
+	return err
+}
+
+// show prints the record, taken from the current page if it's there
+func (sh *shell) show(ctx context.Context, arg string) error {
+	id, err := strconv.Atoi(arg)
+	if err != nil || id <= 0 {
+		return errors.New("show <recid>")
+	}
+
+	var rec *gofmcon.Record
+	for _, r := range sh.records {
+		if r.ID == id {
+			rec = r
+		}
+	}
+	if rec == nil {
+		if sh.layout == "" {
+			return errors.New("no layout, use layout <name> first")
+		}
+		rs, err := sh.fmc.Query(ctx, gofmcon.NewFMQuery(sh.database, sh.layout, gofmcon.Find).WithRecordID(id))
+		if err != nil {
+			return err
+		}
+		if rs.Resultset == nil || len(rs.Resultset.Records) == 0 {
+			return gofmcon.ErrRecordNotFound
+		}
+		rec = rs.Resultset.Records[0]
+	}
+
+	if sh.format != formatTable {
+		return writeRecords(sh.stdout, sh.format, []*gofmcon.Record{rec})
+	}
+
+	tw := tabwriter.NewWriter(sh.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "recid\t%d\n", rec.ID)
+	fmt.Fprintf(tw, "modid\t%d\n", rec.ModID)
+	for _, f := range rec.Fields {
+		fmt.Fprintf(tw, "%s\t%s\n", f.Name, strings.Join(f.Data, ", "))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, set := range rec.RelatedSet {
+		fmt.Fprintf(sh.stdout, "\n%s (%d records)\n", set.Table, set.Count)
+		if err := writeRecords(sh.stdout, formatTable, set.Records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// complete returns the line completed with commands, database and layout
+// names or field names of the layout for find and sort
+func (sh *shell) complete(line string) []string {
+	i := strings.IndexAny(line, " \t")
+	if i < 0 {
+		return completions("", line, []string{
+			"dbs", "layouts", "scripts", "use", "fields", "find", "sort",
+			"next", "prev", "show", "format", "help", "exit",
+		})
+	}
+
+	name, arg := line[:i], strings.TrimLeft(line[i:], " \t")
+	head := line[:len(line)-len(arg)]
+	switch name {
+	case "use":
+		j := strings.IndexAny(arg, " \t")
+		if j < 0 {
+			return completions(head, arg, []string{"db", "layout"})
+		}
+		kind, value := arg[:j], strings.TrimLeft(arg[j:], " \t")
+		head = line[:len(line)-len(value)]
+		switch kind {
+		case "db":
+			return completions(head, value, sh.cachedNames(""))
+		case "layout":
+			return completions(head, value, sh.cachedNames(sh.database))
+		}
+	case "find":
+		j := strings.LastIndexAny(line, " \t(")
+		var names []string
+		for _, fd := range sh.fields {
+			names = append(names, gofmcon.FilterFieldName(fd.Name))
+		}
+		return completions(line[:j+1], line[j+1:], names)
+	case "sort":
+		if j := strings.LastIndex(arg, ","); j >= 0 {
+			arg = strings.TrimLeft(arg[j+1:], " \t")
+			head = line[:len(line)-len(arg)]
+		}
+		var names []string
+		for _, fd := range sh.fields {
+			names = append(names, fd.Name)
+		}
+		return completions(head, arg, names)
+	case "format":
+		return completions(head, arg, []string{formatTable, formatJSON, formatNDJSON, formatCSV})
+	}
+	return nil
+}
+
+// cachedNames returns database names for an empty database, otherwise
+// layout names of the database, which are fetched once
+func (sh *shell) cachedNames(database string) []string {
+	if names, ok := sh.names[database]; ok {
+		return names
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var names []string
+	var err error
+	if database == "" {
+		names, err = sh.fmc.DatabaseNames(ctx)
+	} else {
+		names, err = sh.fmc.LayoutNames(ctx, database)
+	}
+	if err != nil {
+		return nil
+	}
+	sh.names[database] = names
+	return names
+}
+
+// completions returns head followed by every candidate starting with word,
+// field names quoted with backticks also match the word without them
+func completions(head, word string, candidates []string) []string {
+	word = strings.ToLower(word)
+	var lines []string
+	for _, c := range candidates {
+		lower := strings.ToLower(c)
+		if strings.HasPrefix(lower, word) || strings.HasPrefix(strings.TrimPrefix(lower, "`"), word) {
+			lines = append(lines, head+c)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/amanbolat/gofmcon"
+)
+
+const replMetadataXML = `<metadata>
+<field-definition name="name" result="text" type="normal" max-repeat="1" not-empty="yes"/>
+<field-definition name="total" result="number" type="calculation" max-repeat="1"/>
+<field-definition name="first name" result="text" type="normal" max-repeat="1"/>
+<relatedset-definition table="orders"><field-definition name="orders::item" result="text" max-repeat="1"/></relatedset-definition>
+</metadata>`
+
+func replRecordXML(id int) string {
+	return fmt.Sprintf(`<record record-id="%d" mod-id="1">`+
+		`<field name="name"><data>N%d</data></field><field name="total"><data>%d</data></field><field name="first name"><data>F%d</data></field>`+
+		`<relatedset count="1" table="orders"><record record-id="%d" mod-id="1"><field name="orders::item"><data>pen</data></field></record></relatedset>`+
+		`</record>`, id, id, id, id, id*10)
+}
+
+// replResponse answers like a layout with three records
+func replResponse(t *testing.T, rawQuery string) string {
+	switch {
+	case strings.Contains(rawQuery, "-dbnames"):
+		return `<fmresultset><error code="0"/><resultset count="2" fetch-size="2">` +
+			`<record><field name="DATABASE_NAME"><data>sales</data></field></record>` +
+			`<record><field name="DATABASE_NAME"><data>stock</data></field></record>` +
+			`</resultset></fmresultset>`
+	case strings.Contains(rawQuery, "-layoutnames"):
+		return `<fmresultset><error code="0"/><resultset count="2" fetch-size="2">` +
+			`<record><field name="LAYOUT_NAME"><data>customers</data></field></record>` +
+			`<record><field name="LAYOUT_NAME"><data>orders</data></field></record>` +
+			`</resultset></fmresultset>`
+	}
+
+	q, err := gofmcon.ParseFMQuery(rawQuery)
+	if err != nil {
+		t.Errorf("invalid request %s: %v", rawQuery, err)
+		return ""
+	}
+
+	ids := []int{1, 2, 3}
+	if q.RecordID > 0 {
+		ids = []int{q.RecordID}
+	}
+	if q.SkipRecords < len(ids) {
+		ids = ids[q.SkipRecords:]
+	} else {
+		ids = nil
+	}
+	if q.MaxRecords >= 0 && q.MaxRecords < len(ids) {
+		ids = ids[:q.MaxRecords]
+	}
+
+	var records string
+	for _, id := range ids {
+		records += replRecordXML(id)
+	}
+	return fmt.Sprintf(`<fmresultset><error code="0"/>%s<resultset count="3" fetch-size="%d">%s</resultset></fmresultset>`,
+		replMetadataXML, len(ids), records)
+}
+
+func TestREPL(t *testing.T) {
+	t.Setenv("FM_USER", "user")
+	ts := newTestServer(t)
+	ts.respond = func(rawQuery string) string {
+		return replResponse(t, rawQuery)
+	}
+
+	input := strings.Join([]string{
+		"fields",
+		"sort total:desc, first name",
+		`find name = "N1" OR total > 0`,
+		"next",
+		"next",
+		"prev",
+		"show 2",
+		"show 3",
+		"format ndjson",
+		"show 3",
+		"use db stock",
+		"find",
+		"bogus",
+		"exit",
+		"fields",
+	}, "\n")
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), []string{"-host", ts.host, "-port", ts.port, "repl", "-db", "sales", "-layout", "customers", "-page", "2"},
+		strings.NewReader(input), &stdout, &stderr)
+	assert.NoError(t, err)
+
+	queries := make([]*gofmcon.FMQuery, len(ts.queries))
+	for i, raw := range ts.queries {
+		queries[i], err = gofmcon.ParseFMQuery(raw)
+		assert.NoError(t, err)
+	}
+	if assert.Len(t, queries, 6) {
+		// use layout
+		assert.Equal(t, gofmcon.FindAll, queries[0].Action)
+		assert.Equal(t, 0, queries[0].MaxRecords)
+		// find, next, prev
+		for i, skip := range []int{0, 2, 0} {
+			q := queries[i+1]
+			assert.Equal(t, gofmcon.Find, q.Action)
+			assert.Equal(t, `name = "N1" OR total > "0"`, q.FilterString())
+			assert.Equal(t, []gofmcon.FMSortField{
+				{Name: "total", Order: gofmcon.Descending},
+				{Name: "first name", Order: gofmcon.Ascending},
+			}, q.SortFields)
+			assert.Equal(t, 2, q.MaxRecords)
+			assert.Equal(t, skip, q.SkipRecords)
+		}
+		// show 3, which isn't on the page
+		assert.Equal(t, 3, queries[4].RecordID)
+		assert.Equal(t, 3, queries[5].RecordID)
+	}
+
+	out := stdout.String()
+	assert.Contains(t, out, ""+
+		"field         type    repetitions  options\n"+
+		"name          text    1            not empty\n"+
+		"total         number  1            calculation\n"+
+		"first name    text    1            \n"+
+		"orders::item  text    1            \n")
+	assert.Contains(t, out, ""+
+		"recid  modid  name  total  first name\n"+
+		"3      1      N3    3      F3\n")
+	assert.Contains(t, out, ""+
+		"recid       2\n"+
+		"modid       1\n"+
+		"name        N2\n"+
+		"total       2\n"+
+		"first name  F2\n"+
+		"\n"+
+		"orders (1 records)\n"+
+		"recid  modid  orders::item\n"+
+		"20     1      pen\n")
+	assert.Contains(t, out, `{"recordId":3,"modId":1,"fields":{"first name":"F3","name":"N3","orders":[{"item":"pen"}],"total":3}}`+"\n")
+
+	assert.Equal(t, ""+
+		"records 1-2 of 3\n"+
+		"records 3-3 of 3\n"+
+		"error: already on the last page\n"+
+		"records 1-2 of 3\n"+
+		"error: no layout, use layout <name> first\n"+
+		`error: unknown command "bogus", see help`+"\n", stderr.String())
+}
+
+func TestREPLComplete(t *testing.T) {
+	t.Setenv("FM_USER", "user")
+	ts := newTestServer(t)
+	ts.respond = func(rawQuery string) string {
+		return replResponse(t, rawQuery)
+	}
+	c, err := newCLI(options{host: ts.host, port: ts.port}, nil, nil, nil)
+	assert.NoError(t, err)
+	sh := &shell{cli: c, names: map[string][]string{}}
+	sh.database = "sales"
+	sh.fields = gofmcon.FieldsDefinitions{{Name: "name"}, {Name: "total"}, {Name: "first name"}, {Name: "Notes"}}
+
+	assert.Equal(t, []string{"fields", "find", "format"}, sh.complete("f"))
+	assert.Equal(t, []string{"use db"}, sh.complete("use d"))
+	assert.Equal(t, []string{"use db sales", "use db stock"}, sh.complete("use db "))
+	assert.Equal(t, []string{"use db  stock"}, sh.complete("use db  st"))
+	assert.Equal(t, []string{"use layout orders"}, sh.complete("use layout o"))
+	assert.Equal(t, []string{"find name", "find Notes"}, sh.complete("find n"))
+	assert.Equal(t, []string{"find total > 1 AND (`first name`"}, sh.complete("find total > 1 AND (f"))
+	assert.Equal(t, []string{"sort name", "sort Notes"}, sh.complete("sort n"))
+	assert.Equal(t, []string{"sort total:desc, first name"}, sh.complete("sort total:desc, fi"))
+	assert.Equal(t, []string{"format ndjson"}, sh.complete("format nd"))
+	assert.Nil(t, sh.complete("show 1"))
+
+	// names are fetched once
+	assert.Len(t, ts.queries, 2)
+}
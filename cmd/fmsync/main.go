@@ -0,0 +1,100 @@
+// Command fmsync copies records of FileMaker layouts into a SQLite database,
+// so they can be queried with SQL without hitting the live server.
+//
+//	fmsync -host fm.example.com -db sales -o sales.sqlite customers orders
+//	fmsync -host fm.example.com -db sales -o sales.sqlite -modified ModificationTimestamp customers
+//
+// Every layout is written to a table named after it with the record id as
+// the _recid primary key and a column for every field. Repeating fields are
+// written to the child table <layout>__<field> with a row for every repetition,
+// and records of every related set (portal) to the child table <layout>__<table>.
+// Columns which are not fields, as _recid, _modid and _repetition, start with _.
+//
+// With -modified, a timestamp or date field, only records modified since the
+// previous sync are fetched, which doesn't notice deleted records, -full
+// fetches all records again and replaces the tables. The account is read from
+// FM_USER and FM_PASS
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/amanbolat/gofmcon"
+)
+
+type options struct {
+	host, port string
+	database   string
+	output     string
+	modified   string
+	pageSize   int
+	full       bool
+	timeout    time.Duration
+}
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdout, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fmsync:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	var opts options
+	fs := flag.NewFlagSet("fmsync", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.host, "host", os.Getenv("FM_HOST"), "FileMaker server host, defaults to FM_HOST")
+	fs.StringVar(&opts.port, "port", os.Getenv("FM_PORT"), "FileMaker server port, defaults to FM_PORT")
+	fs.StringVar(&opts.database, "db", os.Getenv("FM_DB"), "database name, defaults to FM_DB")
+	fs.StringVar(&opts.output, "o", "", "SQLite database file")
+	fs.StringVar(&opts.modified, "modified", "", "modification timestamp or date field for incremental sync")
+	fs.IntVar(&opts.pageSize, "page", 500, "number of records fetched at a time")
+	fs.BoolVar(&opts.full, "full", false, "fetch all records and replace the tables")
+	fs.DurationVar(&opts.timeout, "timeout", time.Hour, "timeout of the sync of every layout")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: fmsync [flags] layout...")
+		fs.PrintDefaults()
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if opts.host == "" || opts.database == "" || opts.output == "" {
+		return errors.New("-host, -db and -o are required")
+	}
+
+	fmc := gofmcon.NewFMConnector(opts.host, opts.port, "", "")
+	fmc.SetCredentialsProvider(gofmcon.EnvCredentials{})
+
+	s, err := openSyncer(fmc, opts)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	for _, layout := range fs.Args() {
+		lctx, cancel := context.WithTimeout(ctx, opts.timeout)
+		n, err := s.syncLayout(lctx, layout)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("%s: %w", layout, err)
+		}
+		fmt.Fprintf(stdout, "%s: %d records\n", layout, n)
+	}
+	return nil
+}
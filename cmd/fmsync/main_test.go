@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/amanbolat/gofmcon"
+)
+
+type testRecord struct {
+	id, modID int
+	name      string
+	phones    []string
+	total     string
+	modified  string
+	orders    []string
+	invoices  []string
+}
+
+// testServer answers like a customers layout with the records
+type testServer struct {
+	host, port string
+	records    []testRecord
+	// email adds the field to the layout
+	email bool
+	// modifiedType is the type of the modified field, timestamp by default
+	modifiedType string
+	// extra adds an empty text field to the layout
+	extra   string
+	queries []*gofmcon.FMQuery
+}
+
+func newTestServer(t *testing.T) *testServer {
+	ts := &testServer{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, err := gofmcon.ParseFMQuery(r.URL.RawQuery)
+		if err != nil {
+			t.Errorf("invalid request %s: %v", r.URL.RawQuery, err)
+			return
+		}
+		ts.queries = append(ts.queries, q)
+		_, _ = w.Write([]byte(ts.respond(t, q)))
+	}))
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.host, ts.port = u.Hostname(), u.Port()
+	return ts
+}
+
+func (ts *testServer) respond(t *testing.T, q *gofmcon.FMQuery) string {
+	var found []testRecord
+	for _, rec := range ts.records {
+		if q.Action == gofmcon.Find {
+			// the only find is by the modification timestamp, which compares as text in the test
+			f := q.QueryFields[0].Fields[0]
+			if f.Name != "modified" || f.Op != gofmcon.GreaterThanEqual {
+				t.Errorf("unexpected find %s", q.FilterString())
+			}
+			if rec.modified < f.Value {
+				continue
+			}
+		}
+		found = append(found, rec)
+	}
+	if q.SkipRecords < len(found) {
+		found = found[q.SkipRecords:]
+	} else {
+		found = nil
+	}
+	if q.MaxRecords >= 0 && q.MaxRecords < len(found) {
+		found = found[:q.MaxRecords]
+	}
+
+	var b strings.Builder
+	code := 0
+	if len(found) == 0 {
+		code = 401
+	}
+	fmt.Fprintf(&b, `<fmresultset><error code="%d"/><metadata>`, code)
+	b.WriteString(`<field-definition name="name" result="text" max-repeat="1"/>`)
+	b.WriteString(`<field-definition name="phone" result="text" max-repeat="2"/>`)
+	b.WriteString(`<field-definition name="total" result="number" max-repeat="1"/>`)
+	modifiedType := ts.modifiedType
+	if modifiedType == "" {
+		modifiedType = "timestamp"
+	}
+	fmt.Fprintf(&b, `<field-definition name="modified" result="%s" max-repeat="1"/>`, modifiedType)
+	if ts.email {
+		b.WriteString(`<field-definition name="e-mail" result="text" max-repeat="1"/>`)
+	}
+	if ts.extra != "" {
+		fmt.Fprintf(&b, `<field-definition name="%s" result="text" max-repeat="1"/>`, ts.extra)
+	}
+	b.WriteString(`<relatedset-definition table="orders"><field-definition name="orders::item" result="text" max-repeat="1"/></relatedset-definition>`)
+	b.WriteString(`<relatedset-definition table="invoices"><field-definition name="invoices::number" result="number" max-repeat="1"/></relatedset-definition>`)
+	fmt.Fprintf(&b, `</metadata><resultset count="%d" fetch-size="%d">`, len(ts.records), len(found))
+	for _, rec := range found {
+		fmt.Fprintf(&b, `<record record-id="%d" mod-id="%d">`, rec.id, rec.modID)
+		fmt.Fprintf(&b, `<field name="name"><data>%s</data></field>`, rec.name)
+		b.WriteString(`<field name="phone">`)
+		for _, p := range rec.phones {
+			fmt.Fprintf(&b, `<data>%s</data>`, p)
+		}
+		b.WriteString(`</field>`)
+		fmt.Fprintf(&b, `<field name="total"><data>%s</data></field>`, rec.total)
+		fmt.Fprintf(&b, `<field name="modified"><data>%s</data></field>`, rec.modified)
+		if ts.email {
+			fmt.Fprintf(&b, `<field name="e-mail"><data>%s@example.com</data></field>`, strings.ToLower(rec.name))
+		}
+		fmt.Fprintf(&b, `<relatedset count="%d" table="orders">`, len(rec.orders))
+		for i, item := range rec.orders {
+			fmt.Fprintf(&b, `<record record-id="%d" mod-id="1"><field name="orders::item"><data>%s</data></field></record>`, rec.id*10+i, item)
+		}
+		b.WriteString(`</relatedset>`)
+		fmt.Fprintf(&b, `<relatedset count="%d" table="invoices">`, len(rec.invoices))
+		for i, number := range rec.invoices {
+			fmt.Fprintf(&b, `<record record-id="%d" mod-id="1"><field name="invoices::number"><data>%s</data></field></record>`, rec.id*10+i, number)
+		}
+		b.WriteString(`</relatedset></record>`)
+	}
+	b.WriteString(`</resultset></fmresultset>`)
+	return b.String()
+}
+
+func (ts *testServer) run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), append([]string{"-host", ts.host, "-port", ts.port, "-db", "sales"}, args...), &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func queryRows(t *testing.T, db *sql.DB, query string) []string {
+	t.Helper()
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []string
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			t.Fatal(err)
+		}
+		var s []string
+		for _, v := range values {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			s = append(s, fmt.Sprint(v))
+		}
+		result = append(result, strings.Join(s, "|"))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestSync(t *testing.T) {
+	t.Setenv("FM_USER", "user")
+	ts := newTestServer(t)
+	ts.records = []testRecord{
+		{id: 1, modID: 1, name: "Jane", phones: []string{"1", "2"}, total: "10.5", modified: "01/02/2020 10:00:00", orders: []string{"pen", "ink"}, invoices: []string{"7"}},
+		{id: 2, modID: 1, name: "John", phones: []string{"", "3"}, total: "", modified: "01/03/2020 10:00:00"},
+		{id: 3, modID: 1, name: "Bob", total: "n/a", modified: "01/01/2020 10:00:00", orders: []string{"pad"}},
+	}
+	path := filepath.Join(t.TempDir(), "sales.sqlite")
+
+	out, err := ts.run(t, "-o", path, "-modified", "modified", "-page", "2", "customers")
+	assert.NoError(t, err)
+	assert.Equal(t, "customers: 3 records\n", out)
+	assert.Len(t, ts.queries, 2)
+	for _, q := range ts.queries {
+		assert.Equal(t, gofmcon.FindAll, q.Action)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	assert.Equal(t, []string{
+		"1|1|Jane|10.5|2020-01-02 10:00:00",
+		"2|1|John|<nil>|2020-01-03 10:00:00",
+		"3|1|Bob|n/a|2020-01-01 10:00:00",
+	}, queryRows(t, db, `SELECT * FROM customers ORDER BY _recid`))
+	assert.Equal(t, []string{"1|1|1", "1|2|2", "2|2|3"}, queryRows(t, db, `SELECT * FROM customers__phone ORDER BY _recid, _repetition`))
+	assert.Equal(t, []string{"1|10|1|pen", "1|11|1|ink", "3|30|1|pad"}, queryRows(t, db, `SELECT * FROM customers__orders ORDER BY _recid, _related_recid`))
+	assert.Equal(t, []string{"1|10|1|7"}, queryRows(t, db, `SELECT * FROM customers__invoices`))
+	assert.Equal(t, []string{"2020-01-03 10:00:00"}, queryRows(t, db, `SELECT modified FROM fmsync_state WHERE layout = 'customers'`))
+
+	// only records modified since the last sync are fetched,
+	// the field added to the layout is added to the table
+	ts.queries = nil
+	ts.email = true
+	ts.records[0] = testRecord{id: 1, modID: 2, name: "Jane", phones: []string{"5"}, total: "11", modified: "01/04/2020 09:00:00", orders: []string{"pen"}}
+	out, err = ts.run(t, "-o", path, "-modified", "modified", "customers")
+	assert.NoError(t, err)
+	assert.Equal(t, "customers: 2 records\n", out)
+	if assert.Len(t, ts.queries, 1) {
+		assert.Equal(t, `modified >= "01/03/2020 10:00:00"`, ts.queries[0].FilterString())
+	}
+	assert.Equal(t, []string{
+		"1|2|Jane|11|2020-01-04 09:00:00|jane@example.com",
+		"2|1|John|<nil>|2020-01-03 10:00:00|john@example.com",
+		"3|1|Bob|n/a|2020-01-01 10:00:00|<nil>",
+	}, queryRows(t, db, `SELECT _recid, _modid, name, total, modified, "e-mail" FROM customers ORDER BY _recid`))
+	assert.Equal(t, []string{"1|1|5", "2|2|3"}, queryRows(t, db, `SELECT * FROM customers__phone ORDER BY _recid, _repetition`))
+	assert.Equal(t, []string{"1|10|1|pen", "3|30|1|pad"}, queryRows(t, db, `SELECT * FROM customers__orders ORDER BY _recid, _related_recid`))
+	assert.Equal(t, []string{"2020-01-04 09:00:00"}, queryRows(t, db, `SELECT modified FROM fmsync_state WHERE layout = 'customers'`))
+
+	// nothing was found, the state is kept
+	records := ts.records
+	ts.records = nil
+	out, err = ts.run(t, "-o", path, "-modified", "modified", "customers")
+	assert.NoError(t, err)
+	assert.Equal(t, "customers: 0 records\n", out)
+	assert.Equal(t, []string{"2020-01-04 09:00:00"}, queryRows(t, db, `SELECT modified FROM fmsync_state WHERE layout = 'customers'`))
+	assert.Len(t, queryRows(t, db, `SELECT _recid FROM customers`), 3)
+	ts.records = records[:2]
+
+	// full sync removes deleted records
+	out, err = ts.run(t, "-o", path, "-full", "customers")
+	assert.NoError(t, err)
+	assert.Equal(t, "customers: 2 records\n", out)
+	assert.Equal(t, []string{"1", "2"}, queryRows(t, db, `SELECT _recid FROM customers ORDER BY _recid`))
+	assert.Equal(t, []string{"1"}, queryRows(t, db, `SELECT _recid FROM customers__orders`))
+
+	_, err = ts.run(t, "-o", path, "-modified", "updated", "customers")
+	assert.EqualError(t, err, "customers: gofmcon.Export: modification field updated is not on the layout")
+	_, err = ts.run(t, "customers")
+	assert.Error(t, err)
+
+	ts.modifiedType = "time"
+	_, err = ts.run(t, "-o", path, "-modified", "modified", "customers")
+	assert.EqualError(t, err, "customers: gofmcon.Export: modification field modified is a time field, not a timestamp or date")
+	ts.modifiedType = ""
+
+	// the columns fmsync adds are compared ignoring case as SQLite does
+	ts.extra = "_RecID"
+	_, err = ts.run(t, "-o", path, "customers")
+	assert.EqualError(t, err, "customers: gofmcon.Export: table customers: field _RecID collides with another column")
+}
+
+func TestSyncModifiedDate(t *testing.T) {
+	t.Setenv("FM_USER", "user")
+	ts := newTestServer(t)
+	ts.modifiedType = "date"
+	ts.records = []testRecord{
+		{id: 1, modID: 1, name: "Jane", modified: "01/02/2020"},
+		{id: 2, modID: 1, name: "John", modified: "01/03/2020"},
+	}
+	path := filepath.Join(t.TempDir(), "sales.sqlite")
+
+	out, err := ts.run(t, "-o", path, "-modified", "modified", "customers")
+	assert.NoError(t, err)
+	assert.Equal(t, "customers: 2 records\n", out)
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	assert.Equal(t, []string{"2020-01-03"}, queryRows(t, db, `SELECT modified FROM fmsync_state WHERE layout = 'customers'`))
+
+	// records modified on the day of the last sync are fetched again
+	ts.queries = nil
+	ts.records[0].modified = "01/04/2020"
+	out, err = ts.run(t, "-o", path, "-modified", "modified", "customers")
+	assert.NoError(t, err)
+	assert.Equal(t, "customers: 2 records\n", out)
+	if assert.Len(t, ts.queries, 1) {
+		assert.Equal(t, `modified >= "01/03/2020"`, ts.queries[0].FilterString())
+	}
+	assert.Equal(t, []string{"1|2020-01-04", "2|2020-01-03"}, queryRows(t, db, `SELECT _recid, modified FROM customers ORDER BY _recid`))
+	assert.Equal(t, []string{"2020-01-04"}, queryRows(t, db, `SELECT modified FROM fmsync_state WHERE layout = 'customers'`))
+}
+
+func TestUnquote(t *testing.T) {
+	assert.Equal(t, "recid", unquote("recid INTEGER PRIMARY KEY"))
+	assert.Equal(t, `first "x" name`, unquote(quote(`first "x" name`)+" TEXT"))
+	assert.Equal(t, "item", relatedColumn("orders::item"))
+}
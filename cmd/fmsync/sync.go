@@ -0,0 +1,478 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/amanbolat/gofmcon"
+)
+
+// stateTable keeps the greatest modification timestamp synced for every layout
+const stateTable = "fmsync_state"
+
+// stateFormat is the format of timestamps in stateTable and in synced tables
+const stateFormat = "2006-01-02 15:04:05"
+
+// dateFormat is the format of dates in stateTable and in synced tables
+const dateFormat = "2006-01-02"
+
+// syncer copies layouts into the SQLite database
+type syncer struct {
+	fmc      *gofmcon.FMConnector
+	db       *sql.DB
+	database string
+	modified string
+	pageSize int
+	full     bool
+}
+
+func openSyncer(fmc *gofmcon.FMConnector, opts options) (*syncer, error) {
+	db, err := sql.Open("sqlite3", opts.output)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS ` + stateTable + ` (layout TEXT PRIMARY KEY, modified TEXT, synced_at TEXT)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", opts.output, err)
+	}
+
+	return &syncer{
+		fmc:      fmc,
+		db:       db,
+		database: opts.database,
+		modified: opts.modified,
+		pageSize: opts.pageSize,
+		full:     opts.full,
+	}, nil
+}
+
+func (s *syncer) Close() error {
+	return s.db.Close()
+}
+
+// syncLayout fetches the records of the layout and writes them
+// in a single transaction, it returns the number of fetched records
+func (s *syncer) syncLayout(ctx context.Context, layout string) (int, error) {
+	var last string
+	if s.modified != "" && !s.full {
+		err := s.db.QueryRowContext(ctx, `SELECT modified FROM `+stateTable+` WHERE layout = ?`, layout).Scan(&last)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return 0, err
+		}
+	}
+
+	q := gofmcon.NewFMQuery(s.database, layout, gofmcon.FindAll)
+	replace := true
+	if last != "" {
+		// the state of a date field is a date
+		value := ""
+		if t, err := time.Parse(stateFormat, last); err == nil {
+			value = t.Format(gofmcon.TimestampFormat)
+		} else if t, err := time.Parse(dateFormat, last); err == nil {
+			value = t.Format(gofmcon.DateFormat)
+		} else {
+			return 0, fmt.Errorf("invalid modification timestamp %q of the previous sync: %w", last, err)
+		}
+		// records modified in the same second, or on the same day,
+		// as the last one are fetched again
+		q = gofmcon.NewFMQuery(s.database, layout, gofmcon.Find).
+			WithFields(gofmcon.Gte(value).For(s.modified))
+		replace = false
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	// rolls back unless committed
+	defer tx.Rollback()
+
+	w := &tableWriter{ctx: ctx, tx: tx, layout: layout, modified: s.modified, replace: replace}
+	n, err := s.fmc.Export(ctx, q, s.pageSize, w)
+	if err != nil {
+		return n, err
+	}
+
+	modified := last
+	if !w.maxModified.IsZero() {
+		modified = w.maxModified.Format(stateFormat)
+		if w.modifiedDef.Type == gofmcon.TypeDate {
+			modified = w.maxModified.Format(dateFormat)
+		}
+	}
+	_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO `+stateTable+` (layout, modified, synced_at) VALUES (?, ?, ?)`,
+		layout, modified, time.Now().UTC().Format(stateFormat))
+	if err != nil {
+		return n, err
+	}
+	return n, tx.Commit()
+}
+
+// childTable is a table of repetitions of a field or of a related set
+type childTable struct {
+	name string
+	// field is the repeating field, empty for a related set
+	field gofmcon.FieldDefinition
+	// table and fields are the table occurrence and the fields of the related set
+	table  string
+	fields []gofmcon.FieldDefinition
+	insert *sql.Stmt
+	delete *sql.Stmt
+}
+
+// tableWriter is gofmcon.Exporter writing the pages of the
+// layout to its tables. The tables are created or altered
+// from the metadata of the first page
+type tableWriter struct {
+	ctx      context.Context
+	tx       *sql.Tx
+	layout   string
+	modified string
+	// replace deletes all rows before writing the first page
+	replace bool
+
+	fields      []gofmcon.FieldDefinition
+	children    []*childTable
+	insert      *sql.Stmt
+	modifiedDef *gofmcon.FieldDefinition
+	maxModified time.Time
+}
+
+func (w *tableWriter) Write(rs *gofmcon.FMResultset) error {
+	if w.insert == nil {
+		if rs.MetaData == nil {
+			return errors.New("resultset has no metadata")
+		}
+		err := w.prepare(rs.MetaData)
+		if err != nil {
+			return err
+		}
+	}
+
+	if rs.Resultset == nil {
+		return nil
+	}
+	for _, rec := range rs.Resultset.Records {
+		err := w.writeRecord(rec)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", rec.ID, err)
+		}
+	}
+	return nil
+}
+
+// Flush does nothing as the records are committed by syncLayout
+func (w *tableWriter) Flush() error {
+	return nil
+}
+
+func (w *tableWriter) prepare(md *gofmcon.MetaData) error {
+	columns := []string{"_recid INTEGER PRIMARY KEY", "_modid INTEGER"}
+	for _, fd := range md.FieldDefinitions {
+		if fd.MaxRepeat > 1 {
+			w.children = append(w.children, &childTable{name: w.layout + "__" + fd.Name, field: *fd})
+			continue
+		}
+		w.fields = append(w.fields, *fd)
+		columns = append(columns, quote(fd.Name)+" "+columnType(fd.Type))
+		if strings.EqualFold(fd.Name, w.modified) {
+			w.modifiedDef = fd
+		}
+	}
+	if w.modified != "" && w.modifiedDef == nil {
+		return fmt.Errorf("modification field %s is not on the layout", w.modified)
+	}
+	if w.modifiedDef != nil && w.modifiedDef.Type != gofmcon.TypeTimestamp && w.modifiedDef.Type != gofmcon.TypeDate {
+		return fmt.Errorf("modification field %s is a %s field, not a timestamp or date", w.modifiedDef.Name, w.modifiedDef.Type)
+	}
+	for _, set := range md.RelatedSetDefinitions() {
+		related := &childTable{name: w.layout + "__" + set.Table, table: set.Table}
+		for _, fd := range set.FieldDefinitions {
+			related.fields = append(related.fields, *fd)
+		}
+		w.children = append(w.children, related)
+	}
+
+	err := checkColumns(w.layout, columns)
+	if err != nil {
+		return err
+	}
+	err = w.createTable(w.layout, columns, "")
+	if err != nil {
+		return err
+	}
+	w.insert, err = w.prepareInsert(w.layout, columns, "INSERT OR REPLACE")
+	if err != nil {
+		return err
+	}
+
+	tables := map[string]bool{strings.ToLower(w.layout): true}
+	for _, c := range w.children {
+		if tables[strings.ToLower(c.name)] {
+			return fmt.Errorf("table %s is written twice, for a repeating field and a related set", c.name)
+		}
+		tables[strings.ToLower(c.name)] = true
+
+		columns := []string{"_recid INTEGER NOT NULL", "_repetition INTEGER NOT NULL", "_value " + columnType(c.field.Type)}
+		primaryKey := "_recid, _repetition"
+		if c.field.Name == "" {
+			columns = []string{"_recid INTEGER NOT NULL", "_related_recid INTEGER NOT NULL", "_related_modid INTEGER"}
+			for _, fd := range c.fields {
+				columns = append(columns, quote(relatedColumn(fd.Name))+" "+columnType(fd.Type))
+			}
+			primaryKey = "_recid, _related_recid"
+		}
+
+		err = checkColumns(c.name, columns)
+		if err != nil {
+			return err
+		}
+		err = w.createTable(c.name, columns, primaryKey)
+		if err != nil {
+			return err
+		}
+		c.insert, err = w.prepareInsert(c.name, columns, "INSERT")
+		if err != nil {
+			return err
+		}
+		c.delete, err = w.tx.PrepareContext(w.ctx, `DELETE FROM `+quote(c.name)+` WHERE _recid = ?`)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkColumns fails if a field has the same name as another
+// column, e.g. a field named _recid
+func checkColumns(table string, columns []string) error {
+	names := map[string]bool{}
+	for _, column := range columns {
+		name := unquote(column)
+		if names[strings.ToLower(name)] {
+			return fmt.Errorf("table %s: field %s collides with another column", table, name)
+		}
+		names[strings.ToLower(name)] = true
+	}
+	return nil
+}
+
+// createTable creates the table, or adds the columns missing in the
+// existing table, as fields added to the layout. It deletes all rows
+// of the table if the writer replaces them
+func (w *tableWriter) createTable(name string, columns []string, primaryKey string) error {
+	definition := strings.Join(columns, ", ")
+	if primaryKey != "" {
+		definition += ", PRIMARY KEY (" + primaryKey + ")"
+	}
+	_, err := w.tx.ExecContext(w.ctx, `CREATE TABLE IF NOT EXISTS `+quote(name)+` (`+definition+`)`)
+	if err != nil {
+		return fmt.Errorf("create table %s: %w", name, err)
+	}
+
+	existing := map[string]bool{}
+	rows, err := w.tx.QueryContext(w.ctx, `SELECT name FROM pragma_table_info(?)`, name)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[strings.ToLower(column)] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, column := range columns {
+		if existing[strings.ToLower(unquote(column))] {
+			continue
+		}
+		_, err = w.tx.ExecContext(w.ctx, `ALTER TABLE `+quote(name)+` ADD COLUMN `+strings.TrimSuffix(column, " NOT NULL"))
+		if err != nil {
+			return fmt.Errorf("alter table %s: %w", name, err)
+		}
+	}
+
+	if w.replace {
+		_, err = w.tx.ExecContext(w.ctx, `DELETE FROM `+quote(name))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareInsert prepares the insert of the columns by name, as columns
+// added to the existing table are placed after the other ones
+func (w *tableWriter) prepareInsert(name string, columns []string, verb string) (*sql.Stmt, error) {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = quote(unquote(column))
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	return w.tx.PrepareContext(w.ctx, verb+` INTO `+quote(name)+` (`+strings.Join(names, ", ")+`) VALUES (`+placeholders+`)`)
+}
+
+func (w *tableWriter) writeRecord(rec *gofmcon.Record) error {
+	values := []interface{}{rec.ID, rec.ModID}
+	for _, fd := range w.fields {
+		data := fieldData(rec, fd.Name)
+		var v string
+		if len(data) > 0 {
+			v = data[0]
+		}
+		values = append(values, columnValue(v, fd.Type))
+	}
+	_, err := w.insert.ExecContext(w.ctx, values...)
+	if err != nil {
+		return err
+	}
+
+	if w.modifiedDef != nil {
+		data := fieldData(rec, w.modifiedDef.Name)
+		if len(data) > 0 && data[0] != "" {
+			t, err := parseFileMakerTime(data[0], w.modifiedDef.Type)
+			if err != nil {
+				return fmt.Errorf("invalid modification timestamp %q: %w", data[0], err)
+			}
+			if t.After(w.maxModified) {
+				w.maxModified = t
+			}
+		}
+	}
+
+	for _, c := range w.children {
+		_, err = c.delete.ExecContext(w.ctx, rec.ID)
+		if err != nil {
+			return err
+		}
+		if c.field.Name != "" {
+			for i, v := range fieldData(rec, c.field.Name) {
+				if v == "" {
+					continue
+				}
+				_, err = c.insert.ExecContext(w.ctx, rec.ID, i+1, columnValue(v, c.field.Type))
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		for _, set := range rec.RelatedSet {
+			if set.Table != c.table {
+				continue
+			}
+			for _, related := range set.Records {
+				values := []interface{}{rec.ID, related.ID, related.ModID}
+				for _, fd := range c.fields {
+					// repetitions of related fields are not synced
+					data := fieldData(related, fd.Name)
+					var v string
+					if len(data) > 0 {
+						v = data[0]
+					}
+					values = append(values, columnValue(v, fd.Type))
+				}
+				_, err = c.insert.ExecContext(w.ctx, values...)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func fieldData(rec *gofmcon.Record, name string) []string {
+	for _, f := range rec.Fields {
+		if f.Name == name {
+			return f.Data
+		}
+	}
+	return nil
+}
+
+// columnType is the SQLite type of the column of the field type
+func columnType(typ gofmcon.FieldType) string {
+	if typ == gofmcon.TypeNumber {
+		return "REAL"
+	}
+	return "TEXT"
+}
+
+// columnValue converts the value as FileMaker sent it to the value of
+// the column. Dates and timestamps are written in ISO 8601, so they sort
+// and compare as text, values which fail to parse are kept as they are
+func columnValue(v string, typ gofmcon.FieldType) interface{} {
+	if v == "" {
+		return nil
+	}
+
+	switch typ {
+	case gofmcon.TypeNumber:
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	case gofmcon.TypeDate:
+		if t, err := time.Parse(gofmcon.DateFormat, v); err == nil {
+			return t.Format(dateFormat)
+		}
+	case gofmcon.TypeTimestamp:
+		if t, err := time.Parse(gofmcon.TimestampFormat, v); err == nil {
+			return t.Format(stateFormat)
+		}
+	}
+	return v
+}
+
+func parseFileMakerTime(v string, typ gofmcon.FieldType) (time.Time, error) {
+	if typ == gofmcon.TypeDate {
+		return time.Parse(gofmcon.DateFormat, v)
+	}
+	return time.Parse(gofmcon.TimestampFormat, v)
+}
+
+// relatedColumn is the name of the related field without the table occurrence
+func relatedColumn(name string) string {
+	if i := strings.LastIndex(name, "::"); i >= 0 {
+		return name[i+2:]
+	}
+	return name
+}
+
+// quote quotes the identifier, as field names can have spaces and other symbols
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// unquote returns the name of the column from its definition
+func unquote(column string) string {
+	if !strings.HasPrefix(column, `"`) {
+		return column[:strings.Index(column, " ")]
+	}
+	for i := 1; i < len(column); i++ {
+		if column[i] != '"' {
+			continue
+		}
+		if i+1 < len(column) && column[i+1] == '"' {
+			i++
+			continue
+		}
+		return strings.ReplaceAll(column[1:i], `""`, `"`)
+	}
+	return column
+}
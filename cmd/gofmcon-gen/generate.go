@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/amanbolat/gofmcon"
+)
+
+// generator writes Go code for a single layout
+type generator struct {
+	Package  string
+	TypeName string
+	Layout   string
+	Meta     *gofmcon.MetaData
+	// LayoutInfo, if set, adds constants of the value lists used on the layout
+	LayoutInfo *gofmcon.FMLayout
+}
+
+type genField struct {
+	goName string
+	def    gofmcon.FieldDefinition
+	// constName is the constant of the field name
+	constName string
+}
+
+// genPortal is a related set on the layout
+type genPortal struct {
+	table    string
+	typeName string
+	// fieldName is the field of the portal in the struct of the layout
+	fieldName string
+	fields    []genField
+}
+
+// identifiers are the top-level names declared in the generated file
+type identifiers map[string]bool
+
+// declare returns name, or name with a number if it is already declared
+func (ids identifiers) declare(name string) string {
+	unique := name
+	for i := 2; ids[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	ids[unique] = true
+	return unique
+}
+
+func (g *generator) generate() ([]byte, error) {
+	if g.Meta == nil {
+		return nil, fmt.Errorf("layout %s has no metadata", g.Layout)
+	}
+	typeName := g.TypeName
+	if typeName == "" {
+		typeName = goIdent(g.Layout)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by gofmcon-gen from layout %q; DO NOT EDIT.\n\n", g.Layout)
+	fmt.Fprintf(&b, "package %s\n\n", g.Package)
+
+	fields := genFields(g.Meta.FieldDefinitions, "")
+
+	// the types and the names derived from them are declared first, so
+	// other constants and functions get a suffix when they collide
+	ids := identifiers{}
+	ids.declare(typeName)
+	layoutConst := ids.declare(typeName + "Layout")
+	newRepository := ids.declare("New" + typeName + "Repository")
+
+	// portal fields are named after their tables, unlike the other fields
+	fieldNames := identifiers{"RecordID": true, "ModID": true, "Layout": true}
+	for _, f := range fields {
+		fieldNames[f.goName] = true
+	}
+	var portals []genPortal
+	for _, rs := range g.Meta.RelatedSetDefinitions() {
+		name := goIdent(rs.Table)
+		if fieldNames[name] {
+			name += "Set"
+		}
+		portals = append(portals, genPortal{
+			table:     rs.Table,
+			typeName:  ids.declare(typeName + goIdent(rs.Table)),
+			fieldName: fieldNames.declare(name),
+			fields:    genFields(rs.FieldDefinitions, rs.Table),
+		})
+	}
+
+	usesTime := false
+	all := append([]genField(nil), fields...)
+	for _, p := range portals {
+		all = append(all, p.fields...)
+	}
+	for _, f := range all {
+		if goFieldType(f.def) == "time.Time" {
+			usesTime = true
+		}
+	}
+	b.WriteString("import (\n")
+	if usesTime {
+		b.WriteString("\t\"time\"\n\n")
+	}
+	b.WriteString("\t\"github.com/amanbolat/gofmcon\"\n)\n\n")
+
+	fmt.Fprintf(&b, "// %s is the name of the layout\n", layoutConst)
+	fmt.Fprintf(&b, "const %s = %q\n\n", layoutConst, g.Layout)
+
+	fmt.Fprintf(&b, "// Field names of layout %s\n", g.Layout)
+	b.WriteString("const (\n")
+	for i := range fields {
+		fields[i].constName = ids.declare(typeName + "Field" + fields[i].goName)
+		fmt.Fprintf(&b, "\t%s = %q\n", fields[i].constName, fields[i].def.Name)
+	}
+	for _, p := range portals {
+		for i := range p.fields {
+			p.fields[i].constName = ids.declare(typeName + goIdent(p.table) + "Field" + p.fields[i].goName)
+			fmt.Fprintf(&b, "\t%s = %q\n", p.fields[i].constName, p.fields[i].def.Name)
+		}
+	}
+	b.WriteString(")\n\n")
+
+	g.writeValueLists(&b, ids, typeName, fields)
+
+	fmt.Fprintf(&b, "// %s is a record of layout %s\n", typeName, g.Layout)
+	fmt.Fprintf(&b, "type %s struct {\n", typeName)
+	writeStructFields(&b, fields)
+	for _, p := range portals {
+		fmt.Fprintf(&b, "\t%s []%s `fm:\"%s,portal\"`\n", p.fieldName, p.typeName, p.table)
+	}
+	b.WriteString("}\n\n")
+
+	for _, p := range portals {
+		fmt.Fprintf(&b, "// %s is a record of related set %s on layout %s\n", p.typeName, p.table, g.Layout)
+		fmt.Fprintf(&b, "type %s struct {\n", p.typeName)
+		writeStructFields(&b, p.fields)
+		b.WriteString("}\n\n")
+	}
+
+	fmt.Fprintf(&b, "// %s creates gofmcon.Repository of layout %s\n", newRepository, g.Layout)
+	fmt.Fprintf(&b, "func %s(fmc *gofmcon.FMConnector, database string) (*gofmcon.Repository[%s], error) {\n", newRepository, typeName)
+	fmt.Fprintf(&b, "\treturn gofmcon.NewRepository[%s](fmc, database, %s)\n}\n\n", typeName, layoutConst)
+
+	for _, f := range fields {
+		name := ids.declare(typeName + f.goName)
+		fmt.Fprintf(&b, "// %s matches records of layout %s by field %s\n", name, g.Layout, f.def.Name)
+		fmt.Fprintf(&b, "func %s(cond gofmcon.FMCondition) gofmcon.Expr {\n", name)
+		fmt.Fprintf(&b, "\treturn gofmcon.FieldExpr(%s, cond)\n}\n\n", f.constName)
+	}
+
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return nil, fmt.Errorf("error format generated code: %w", err)
+	}
+	return src, nil
+}
+
+func (g *generator) writeValueLists(b *strings.Builder, ids identifiers, typeName string, fields []genField) {
+	if g.LayoutInfo == nil {
+		return
+	}
+
+	var names []string
+	seen := map[string]bool{}
+	for _, f := range fields {
+		name := g.LayoutInfo.FieldValueList(f.def.Name)
+		if name != "" && !seen[name] && g.LayoutInfo.ValueList(name) != nil {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		vl := g.LayoutInfo.ValueList(name)
+		fmt.Fprintf(b, "// Values of value list %s\n", name)
+		b.WriteString("const (\n")
+		for _, item := range vl.Values {
+			if item.Value == "" {
+				continue
+			}
+			ident := ids.declare(typeName + goIdent(name) + goIdent(item.Value))
+			fmt.Fprintf(b, "\t%s = %q\n", ident, item.Value)
+		}
+		b.WriteString(")\n\n")
+	}
+}
+
+// genFields names the fields, fields of a related set
+// are named without the table prefix
+func genFields(defs []*gofmcon.FieldDefinition, table string) []genField {
+	var fields []genField
+	used := map[string]bool{"RecordID": true, "ModID": true, "Layout": true}
+	for _, def := range defs {
+		base := def.Name
+		if table != "" && len(base) > len(table)+2 && strings.EqualFold(base[:len(table)+2], table+"::") {
+			base = base[len(table)+2:]
+		}
+		name := goIdent(base)
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s%d", goIdent(base), i)
+		}
+		used[name] = true
+		fields = append(fields, genField{goName: name, def: *def})
+	}
+	return fields
+}
+
+func writeStructFields(b *strings.Builder, fields []genField) {
+	b.WriteString("\tRecordID int `fm:\",recid\"`\n")
+	b.WriteString("\tModID int `fm:\",modid\"`\n")
+	for _, f := range fields {
+		tag := f.def.Name
+		var notes []string
+		switch f.def.Kind {
+		case gofmcon.KindCalculation, gofmcon.KindSummary:
+			tag += ",readonly"
+			notes = append(notes, string(f.def.Kind))
+		}
+		if f.def.Global {
+			notes = append(notes, "global")
+		}
+		if f.def.NotEmpty {
+			notes = append(notes, "not empty")
+		}
+		if f.def.MaxRepeat > 1 {
+			notes = append(notes, fmt.Sprintf("%d repetitions", f.def.MaxRepeat))
+		}
+
+		fmt.Fprintf(b, "\t%s %s `fm:%q`", f.goName, goFieldType(f.def), tag)
+		if len(notes) > 0 {
+			fmt.Fprintf(b, " // %s", strings.Join(notes, ", "))
+		}
+		b.WriteString("\n")
+	}
+}
+
+func goFieldType(def gofmcon.FieldDefinition) string {
+	var t string
+	switch def.Type {
+	case gofmcon.TypeNumber:
+		t = "float64"
+	case gofmcon.TypeDate, gofmcon.TypeTime, gofmcon.TypeTimestamp:
+		t = "time.Time"
+	default:
+		t = "string"
+	}
+	if def.MaxRepeat > 1 {
+		return "[]" + t
+	}
+	return t
+}
+
+// commonInitialisms are written in upper case as golint expects
+var commonInitialisms = map[string]bool{
+	"ID": true, "URL": true, "API": true, "HTTP": true, "JSON": true, "XML": true, "UUID": true,
+}
+
+// goIdent turns a FileMaker name such as "profile::full_name" into an
+// exported Go identifier such as ProfileFullName
+func goIdent(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var b strings.Builder
+	for _, w := range words {
+		if commonInitialisms[strings.ToUpper(w)] {
+			b.WriteString(strings.ToUpper(w))
+			continue
+		}
+		runes := []rune(w)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+
+	ident := b.String()
+	if ident == "" {
+		return "Field"
+	}
+	if first := []rune(ident)[0]; !unicode.IsLetter(first) || !unicode.IsUpper(first) {
+		ident = "F" + ident
+	}
+	return ident
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const customersXML = `<fmresultset xmlns="http://www.filemaker.com/xml/fmresultset" version="1.0">
+<error code="0"/>
+<datasource database="db" layout="customers" table="customers" total-count="3"/>
+<metadata>
+<field-definition name="id" result="number" type="normal" max-repeat="1" auto-enter="yes"/>
+<field-definition name="full_name" result="text" type="normal" max-repeat="1" not-empty="yes"/>
+<field-definition name="phone" result="text" type="normal" max-repeat="3"/>
+<field-definition name="status" result="text" type="normal" max-repeat="1"/>
+<field-definition name="since" result="date" type="normal" max-repeat="1"/>
+<field-definition name="total" result="number" type="calculation" max-repeat="1"/>
+<relatedset-definition table="orders">
+<field-definition name="orders::amount" result="number" type="normal" max-repeat="1"/>
+</relatedset-definition>
+</metadata>
+<resultset count="0" fetch-size="0"/>
+</fmresultset>`
+
+const customersLayoutXML = `<FMPXMLLAYOUT xmlns="http://www.filemaker.com/fmpxmllayout">
+<ERRORCODE>0</ERRORCODE>
+<LAYOUT DATABASE="db" NAME="customers">
+<FIELD NAME="status"><STYLE TYPE="POPUPMENU" VALUELIST="Statuses"/></FIELD>
+</LAYOUT>
+<VALUELISTS>
+<VALUELIST NAME="Statuses"><VALUE DISPLAY="Open">open</VALUE><VALUE DISPLAY="On hold">on hold</VALUE></VALUELIST>
+</VALUELISTS>
+</FMPXMLLAYOUT>`
+
+func writeTestFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGenerate(t *testing.T) {
+	opts := options{
+		xmlPath:     writeTestFile(t, "customers.xml", customersXML),
+		layoutPath:  writeTestFile(t, "layout.xml", customersLayoutXML),
+		packageName: "models",
+	}
+
+	var out bytes.Buffer
+	assert.NoError(t, run(opts, &out))
+	src := out.String()
+	// gofmt alignment doesn't matter here
+	compact := strings.Join(strings.Fields(src), " ")
+
+	for _, want := range []string{
+		`// Code generated by gofmcon-gen from layout "customers"; DO NOT EDIT.`,
+		`const CustomersLayout = "customers"`,
+		`CustomersFieldFullName    = "full_name"`,
+		`CustomersOrdersFieldAmount = "orders::amount"`,
+		`CustomersStatusesOpen   = "open"`,
+		`CustomersStatusesOnHold = "on hold"`,
+		"ID       float64        `fm:\"id\"`",
+		"FullName string         `fm:\"full_name\"`       // not empty",
+		"Phone    []string       `fm:\"phone\"`           // 3 repetitions",
+		"Since    time.Time      `fm:\"since\"`",
+		"Total    float64        `fm:\"total,readonly\"`  // calculation",
+		"Orders   []CustomersOrders `fm:\"orders,portal\"`",
+		"Amount   float64 `fm:\"orders::amount\"`",
+		`func NewCustomersRepository(fmc *gofmcon.FMConnector, database string) (*gofmcon.Repository[Customers], error) {`,
+		`func CustomersStatus(cond gofmcon.FMCondition) gofmcon.Expr {`,
+	} {
+		assert.Contains(t, compact, strings.Join(strings.Fields(want), " "))
+	}
+
+	opts.output = writeTestFile(t, "customers_gen.go", src)
+	opts.check = true
+	assert.NoError(t, run(opts, &out))
+
+	opts.xmlPath = writeTestFile(t, "changed.xml", `<fmresultset><error code="0"/>
+<datasource layout="customers"/><metadata><field-definition name="id" result="text"/></metadata></fmresultset>`)
+	err := run(opts, &out)
+	assert.True(t, errors.Is(err, errDrift))
+}
+
+func TestGenerateCollidingNames(t *testing.T) {
+	// the field orders and the related table orders both derive CustomersOrders,
+	// the field orders_field_item and the related field orders::item both
+	// derive CustomersOrdersFieldItem
+	opts := options{
+		xmlPath: writeTestFile(t, "customers.xml", `<fmresultset><error code="0"/>
+<datasource layout="customers"/><metadata>
+<field-definition name="orders" result="text" max-repeat="1"/>
+<field-definition name="orders_field_item" result="text" max-repeat="1"/>
+<relatedset-definition table="orders">
+<field-definition name="orders::item" result="text" max-repeat="1"/>
+</relatedset-definition>
+</metadata></fmresultset>`),
+		packageName: "models",
+	}
+
+	var out bytes.Buffer
+	assert.NoError(t, run(opts, &out))
+
+	f, err := parser.ParseFile(token.NewFileSet(), "customers_gen.go", out.Bytes(), 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	declared := map[string]bool{}
+	for _, obj := range f.Scope.Objects {
+		declared[obj.Name] = obj.Kind == ast.Typ || obj.Kind == ast.Con || obj.Kind == ast.Fun
+	}
+	// the parser reports redeclared names only as errors with AllErrors,
+	// so every name must be found once in the declarations
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			assert.True(t, declared[d.Name.Name], d.Name.Name)
+			delete(declared, d.Name.Name)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					assert.True(t, declared[s.Name.Name], s.Name.Name)
+					delete(declared, s.Name.Name)
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						assert.True(t, declared[n.Name], n.Name)
+						delete(declared, n.Name)
+					}
+				}
+			}
+		}
+	}
+
+	compact := strings.Join(strings.Fields(out.String()), " ")
+	for _, want := range []string{
+		`type CustomersOrders struct {`,
+		"OrdersSet []CustomersOrders `fm:\"orders,portal\"`",
+		`CustomersOrdersFieldItem = "orders::item"`,
+		`func CustomersOrders2(cond gofmcon.FMCondition) gofmcon.Expr { return gofmcon.FieldExpr(CustomersFieldOrders, cond) }`,
+		`func CustomersOrdersFieldItem2(cond gofmcon.FMCondition) gofmcon.Expr { return gofmcon.FieldExpr(CustomersFieldOrdersFieldItem, cond) }`,
+	} {
+		assert.Contains(t, compact, strings.Join(strings.Fields(want), " "))
+	}
+}
+
+func TestGenerateRelatedSets(t *testing.T) {
+	opts := options{
+		xmlPath: writeTestFile(t, "customers.xml", `<fmresultset><error code="0"/>
+<datasource layout="customers"/><metadata>
+<field-definition name="name" result="text" max-repeat="1"/>
+<relatedset-definition table="orders">
+<field-definition name="orders::total" result="number" max-repeat="1"/>
+</relatedset-definition>
+<relatedset-definition table="payments">
+<field-definition name="payments::amount" result="number" max-repeat="1"/>
+<field-definition name="payments::paid" result="date" max-repeat="1"/>
+</relatedset-definition>
+</metadata></fmresultset>`),
+		packageName: "models",
+	}
+
+	var out bytes.Buffer
+	assert.NoError(t, run(opts, &out))
+	_, err := parser.ParseFile(token.NewFileSet(), "customers_gen.go", out.Bytes(), 0)
+	assert.NoError(t, err)
+
+	compact := strings.Join(strings.Fields(out.String()), " ")
+	for _, want := range []string{
+		`CustomersOrdersFieldTotal = "orders::total"`,
+		`CustomersPaymentsFieldAmount = "payments::amount"`,
+		"Orders []CustomersOrders `fm:\"orders,portal\"`",
+		"Payments []CustomersPayments `fm:\"payments,portal\"`",
+		"type CustomersOrders struct { RecordID int `fm:\",recid\"` ModID int `fm:\",modid\"` Total float64 `fm:\"orders::total\"` }",
+		"type CustomersPayments struct { RecordID int `fm:\",recid\"` ModID int `fm:\",modid\"` Amount float64 `fm:\"payments::amount\"` Paid time.Time `fm:\"payments::paid\"` }",
+	} {
+		assert.Contains(t, compact, strings.Join(strings.Fields(want), " "))
+	}
+	assert.NotContains(t, compact, "PaymentsFieldOrders")
+}
+
+func TestGoIdent(t *testing.T) {
+	for name, ident := range map[string]string{
+		"full_name":      "FullName",
+		"profile::email": "ProfileEmail",
+		"customer id":    "CustomerID",
+		"2nd address":    "F2ndAddress",
+		"::":             "Field",
+		"Straße":         "Straße",
+	} {
+		assert.Equal(t, ident, goIdent(name), name)
+	}
+}
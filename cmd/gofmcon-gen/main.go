@@ -0,0 +1,156 @@
+// Command gofmcon-gen generates Go structs, field name constants and query
+// helpers for a FileMaker layout, either from a live server or from a saved
+// fmresultset XML response.
+//
+//	gofmcon-gen -host fm.example.com -db sales -layout customers -package models -o customers_gen.go
+//	gofmcon-gen -xml customers.xml -layout-xml customers_layout.xml -package models -o customers_gen.go
+//
+// The account is read from FM_USER and FM_PASS. With -check nothing is written,
+// instead the command fails if the output file differs from the generated code,
+// so CI notices when the layout changed
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/amanbolat/gofmcon"
+)
+
+// errDrift is returned by -check when the output file is out of date
+var errDrift = errors.New("generated code is out of date, run gofmcon-gen again")
+
+type options struct {
+	host, port  string
+	database    string
+	layout      string
+	xmlPath     string
+	layoutPath  string
+	packageName string
+	typeName    string
+	output      string
+	check       bool
+	timeout     time.Duration
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.host, "host", os.Getenv("FM_HOST"), "FileMaker server host, defaults to FM_HOST")
+	flag.StringVar(&opts.port, "port", os.Getenv("FM_PORT"), "FileMaker server port, defaults to FM_PORT")
+	flag.StringVar(&opts.database, "db", "", "database name")
+	flag.StringVar(&opts.layout, "layout", "", "layout name, defaults to the layout of -xml")
+	flag.StringVar(&opts.xmlPath, "xml", "", "saved fmresultset response to generate from instead of the server")
+	flag.StringVar(&opts.layoutPath, "layout-xml", "", "saved FMPXMLLAYOUT response with value lists, used with -xml")
+	flag.StringVar(&opts.packageName, "package", "models", "package name of the generated file")
+	flag.StringVar(&opts.typeName, "type", "", "name of the generated struct, defaults to the layout name")
+	flag.StringVar(&opts.output, "o", "", "output file, defaults to stdout")
+	flag.BoolVar(&opts.check, "check", false, "fail if the output file differs from the generated code")
+	flag.DurationVar(&opts.timeout, "timeout", time.Minute, "timeout of requests to the server")
+	flag.Parse()
+
+	err := run(opts, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gofmcon-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options, stdout io.Writer) error {
+	g, err := loadGenerator(opts)
+	if err != nil {
+		return err
+	}
+
+	src, err := g.generate()
+	if err != nil {
+		return err
+	}
+
+	if opts.check {
+		if opts.output == "" {
+			return errors.New("-check requires -o")
+		}
+		existing, err := os.ReadFile(opts.output)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(existing, src) {
+			return fmt.Errorf("%s: %w", opts.output, errDrift)
+		}
+		return nil
+	}
+
+	if opts.output == "" {
+		_, err = stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(opts.output, src, 0o644)
+}
+
+func loadGenerator(opts options) (*generator, error) {
+	g := &generator{Package: opts.packageName, TypeName: opts.typeName, Layout: opts.layout}
+
+	if opts.xmlPath != "" {
+		data, err := os.ReadFile(opts.xmlPath)
+		if err != nil {
+			return nil, err
+		}
+		var rs gofmcon.FMResultset
+		err = xml.Unmarshal(data, &rs)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", opts.xmlPath, err)
+		}
+		if rs.HasError() {
+			return nil, fmt.Errorf("%s: %w", opts.xmlPath, &rs.FMError)
+		}
+		g.Meta = rs.MetaData
+		if g.Layout == "" && rs.DataSource != nil {
+			g.Layout = rs.DataSource.Layout
+		}
+
+		if opts.layoutPath != "" {
+			data, err := os.ReadFile(opts.layoutPath)
+			if err != nil {
+				return nil, err
+			}
+			l, err := gofmcon.ParseLayout(data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", opts.layoutPath, err)
+			}
+			g.LayoutInfo = &l
+		}
+	} else {
+		if opts.host == "" || opts.database == "" || opts.layout == "" {
+			return nil, errors.New("-host, -db and -layout are required without -xml")
+		}
+
+		fmc := gofmcon.NewFMConnector(opts.host, opts.port, "", "")
+		fmc.SetCredentialsProvider(gofmcon.EnvCredentials{})
+		ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+		defer cancel()
+
+		rs, err := fmc.Query(ctx, gofmcon.NewFMQuery(opts.database, opts.layout, gofmcon.FindAll).Max(0))
+		if err != nil && !errors.Is(err, gofmcon.ErrRecordNotFound) {
+			return nil, err
+		}
+		g.Meta = rs.MetaData
+
+		l, err := fmc.Layout(ctx, opts.database, opts.layout)
+		if err != nil {
+			return nil, err
+		}
+		g.LayoutInfo = &l
+	}
+
+	if g.Layout == "" {
+		return nil, errors.New("-layout is required")
+	}
+	return g, nil
+}
@@ -0,0 +1,135 @@
+package gofmcon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// EnvUsername is the default environment variable EnvCredentials reads the username from
+	EnvUsername = "FM_USER"
+	// EnvPassword is the default environment variable EnvCredentials reads the password from
+	EnvPassword = "FM_PASS"
+)
+
+// Credentials is a FileMaker account used to authorize a request
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// CredentialsProvider returns the FileMaker account a request should be sent with.
+// It is consulted by FMConnector for every request, so implementations
+// must be safe for concurrent use
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// StaticCredentials always returns the same account
+type StaticCredentials Credentials
+
+// Credentials implements CredentialsProvider
+func (c StaticCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	return Credentials(c), nil
+}
+
+// EnvCredentials reads the account from environment variables on every request.
+// Empty variable names fall back to EnvUsername and EnvPassword
+type EnvCredentials struct {
+	UsernameVar string
+	PasswordVar string
+}
+
+// Credentials implements CredentialsProvider
+func (c EnvCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	userVar := c.UsernameVar
+	if userVar == "" {
+		userVar = EnvUsername
+	}
+	passVar := c.PasswordVar
+	if passVar == "" {
+		passVar = EnvPassword
+	}
+
+	username, ok := os.LookupEnv(userVar)
+	if !ok {
+		return Credentials{}, fmt.Errorf("gofmcon.EnvCredentials: %s is not set", userVar)
+	}
+
+	return Credentials{Username: username, Password: os.Getenv(passVar)}, nil
+}
+
+// FileCredentials reads the account from a JSON file of the form
+// {"username": "...", "password": "..."}. The file is read again whenever
+// its modification time or size changes, so credentials can be rotated
+// without restarting the process
+type FileCredentials struct {
+	path    string
+	mu      sync.Mutex
+	creds   Credentials
+	modTime time.Time
+	size    int64
+}
+
+// NewFileCredentials creates FileCredentials for the file at the given path
+// and reads it once to make sure it is valid
+func NewFileCredentials(path string) (*FileCredentials, error) {
+	fc := &FileCredentials{path: path}
+	_, err := fc.Credentials(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return fc, nil
+}
+
+// Credentials implements CredentialsProvider
+func (fc *FileCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	info, err := os.Stat(fc.path)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("gofmcon.FileCredentials: error stat file: %w", err)
+	}
+
+	if info.ModTime().Equal(fc.modTime) && info.Size() == fc.size {
+		return fc.creds, nil
+	}
+
+	b, err := os.ReadFile(fc.path)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("gofmcon.FileCredentials: error read file: %w", err)
+	}
+
+	var creds Credentials
+	err = json.Unmarshal(b, &creds)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("gofmcon.FileCredentials: error unmarshal json: %w", err)
+	}
+
+	fc.creds = creds
+	fc.modTime = info.ModTime()
+	fc.size = info.Size()
+
+	return fc.creds, nil
+}
+
+type credentialsCtxKey struct{}
+
+// ContextWithCredentials returns a copy of ctx carrying the account that
+// FMConnector must use for requests made with it. It takes precedence over
+// any CredentialsProvider and over FMConnector Username and Password
+func ContextWithCredentials(ctx context.Context, username, password string) context.Context {
+	return context.WithValue(ctx, credentialsCtxKey{}, Credentials{Username: username, Password: password})
+}
+
+// CredentialsFromContext returns the account stored by ContextWithCredentials
+func CredentialsFromContext(ctx context.Context) (Credentials, bool) {
+	creds, ok := ctx.Value(credentialsCtxKey{}).(Credentials)
+	return creds, ok
+}
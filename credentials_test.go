@@ -0,0 +1,54 @@
+package gofmcon
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialsPrecedence(t *testing.T) {
+	var gotUser string
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		gotUser, _, _ = r.BasicAuth()
+		_, _ = w.Write([]byte(emptyResultsetXML))
+	})
+	q := NewFMQuery("db", "layout", FindAll)
+
+	_, err := conn.Query(context.Background(), q)
+	assert.NoError(t, err)
+	assert.Equal(t, "user", gotUser)
+
+	conn.SetCredentialsProvider(StaticCredentials{Username: "provider"})
+	_, err = conn.Query(context.Background(), q)
+	assert.NoError(t, err)
+	assert.Equal(t, "provider", gotUser)
+
+	ctx := ContextWithCredentials(context.Background(), "end_user", "secret")
+	_, err = conn.Query(ctx, q)
+	assert.NoError(t, err)
+	assert.Equal(t, "end_user", gotUser)
+}
+
+func TestFileCredentialsReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "creds.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"username":"first","password":"a"}`), 0600))
+
+	fc, err := NewFileCredentials(path)
+	assert.NoError(t, err)
+	creds, err := fc.Credentials(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "first", creds.Username)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"username":"second","password":"b"}`), 0600))
+	later := time.Now().Add(time.Second)
+	assert.NoError(t, os.Chtimes(path, later, later))
+
+	creds, err = fc.Credentials(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{Username: "second", Password: "b"}, creds)
+}
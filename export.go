@@ -0,0 +1,381 @@
+package gofmcon
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RepetitionMode tells how CSV and TSV exporters write repeating fields
+type RepetitionMode int
+
+const (
+	// JoinRepetitions writes all repetitions to one column joined with the separator
+	JoinRepetitions RepetitionMode = iota
+	// SplitRepetitions writes every repetition to its own column, e.g. phone(1) and phone(2)
+	SplitRepetitions
+	// FirstRepetition writes only the first repetition
+	FirstRepetition
+)
+
+// RelatedSetMode tells how CSV and TSV exporters write records of the related set
+type RelatedSetMode int
+
+const (
+	// OmitRelatedSets doesn't write related records
+	OmitRelatedSets RelatedSetMode = iota
+	// JoinRelatedSets writes a column for every related field with the values
+	// of all related records joined with the separator
+	JoinRelatedSets
+	// ExpandRelatedSets writes a row for every related record repeating the values
+	// of the record, a record without related records is written as one row
+	ExpandRelatedSets
+)
+
+// ExportOptions configures CSV and TSV exporters
+type ExportOptions struct {
+	Repetitions RepetitionMode
+	RelatedSets RelatedSetMode
+	// Separator joins repetitions and related values, "\n" by default
+	Separator string
+	// RecordIDs adds the record id and the mod id as the first columns
+	RecordIDs bool
+	// NoHeader omits the row with field names
+	NoHeader bool
+	// Raw writes values as FileMaker sent them. By default numbers are written
+	// without grouping, dates, times and timestamps in ISO 8601,
+	// e.g. 2006-01-02, 15:04:05 and 2006-01-02 15:04:05
+	Raw bool
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+const (
+	exportDateFormat      = "2006-01-02"
+	exportTimestampFormat = "2006-01-02 15:04:05"
+)
+
+// Exporter writes records of resultsets, e.g. the pages of FMConnector.Export
+type Exporter interface {
+	Write(rs *FMResultset) error
+	Flush() error
+}
+
+// TableExporter writes records as CSV or TSV rows. The columns
+// are made from the field definitions of the first resultset
+type TableExporter struct {
+	w       io.Writer
+	csv     *csv.Writer
+	opts    ExportOptions
+	columns []exportColumn
+}
+
+// exportColumn is a column of a field, or a single repetition of it
+type exportColumn struct {
+	header string
+	def    FieldDefinition
+	rep    int // all repetitions if 0
+	// table is the related set of the field, empty for fields of the record
+	table string
+}
+
+// relatedRecord is a record of the related set of the table
+type relatedRecord struct {
+	table string
+	rec   *Record
+}
+
+// NewCSVExporter creates TableExporter writing CSV
+func NewCSVExporter(w io.Writer, opts ExportOptions) *TableExporter {
+	return &TableExporter{w: w, csv: csv.NewWriter(w), opts: opts}
+}
+
+// NewTSVExporter creates TableExporter writing tab separated values.
+// Backslashes, tabs and line breaks in values are escaped as \\, \t, \n and \r
+func NewTSVExporter(w io.Writer, opts ExportOptions) *TableExporter {
+	return &TableExporter{w: w, opts: opts}
+}
+
+// Write writes the records of the resultset, and the header
+// before the records of the first resultset
+func (e *TableExporter) Write(rs *FMResultset) error {
+	if e.columns == nil {
+		if rs.MetaData == nil {
+			return errors.New("gofmcon.TableExporter: resultset has no metadata")
+		}
+		e.columns = e.makeColumns(rs.MetaData)
+		if !e.opts.NoHeader {
+			var header []string
+			if e.opts.RecordIDs {
+				header = append(header, "recid", "modid")
+			}
+			for _, c := range e.columns {
+				header = append(header, c.header)
+			}
+			if err := e.writeRow(header); err != nil {
+				return fmt.Errorf("gofmcon.TableExporter: %w", err)
+			}
+		}
+	}
+
+	if rs.Resultset == nil {
+		return nil
+	}
+	for _, rec := range rs.Resultset.Records {
+		for _, row := range e.rows(rec) {
+			if err := e.writeRow(row); err != nil {
+				return fmt.Errorf("gofmcon.TableExporter: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Flush writes buffered rows to the underlying writer
+func (e *TableExporter) Flush() error {
+	if e.csv == nil {
+		return nil
+	}
+	e.csv.Flush()
+	if err := e.csv.Error(); err != nil {
+		return fmt.Errorf("gofmcon.TableExporter: %w", err)
+	}
+	return nil
+}
+
+func (e *TableExporter) writeRow(row []string) error {
+	if e.csv != nil {
+		return e.csv.Write(row)
+	}
+
+	for i := range row {
+		row[i] = tsvEscaper.Replace(row[i])
+	}
+	_, err := io.WriteString(e.w, strings.Join(row, "\t")+"\n")
+	return err
+}
+
+func (e *TableExporter) makeColumns(md *MetaData) []exportColumn {
+	columns := []exportColumn{}
+	add := func(defs []*FieldDefinition, table string) {
+		for _, def := range defs {
+			switch {
+			case e.opts.Repetitions == SplitRepetitions && def.MaxRepeat > 1:
+				for i := 1; i <= def.MaxRepeat; i++ {
+					header := fmt.Sprintf("%s(%d)", def.Name, i)
+					columns = append(columns, exportColumn{header: header, def: *def, rep: i, table: table})
+				}
+			case e.opts.Repetitions == JoinRepetitions:
+				columns = append(columns, exportColumn{header: def.Name, def: *def, table: table})
+			default:
+				columns = append(columns, exportColumn{header: def.Name, def: *def, rep: 1, table: table})
+			}
+		}
+	}
+
+	add(md.FieldDefinitions, "")
+	if e.opts.RelatedSets != OmitRelatedSets {
+		for _, set := range md.RelatedSetDefinitions() {
+			add(set.FieldDefinitions, set.Table)
+		}
+	}
+	return columns
+}
+
+// rows returns the rows of the record, more than one
+// if its related records are expanded. Columns of a related
+// set are filled only from the records of that set
+func (e *TableExporter) rows(rec *Record) [][]string {
+	related := map[string][]*Record{}
+	var all []relatedRecord
+	if e.opts.RelatedSets != OmitRelatedSets {
+		for _, set := range rec.RelatedSet {
+			related[set.Table] = append(related[set.Table], set.Records...)
+			for _, r := range set.Records {
+				all = append(all, relatedRecord{table: set.Table, rec: r})
+			}
+		}
+	}
+
+	expand := []relatedRecord{{}}
+	if e.opts.RelatedSets == ExpandRelatedSets && len(all) > 0 {
+		expand = all
+	}
+
+	var rows [][]string
+	for _, relatedRec := range expand {
+		var row []string
+		if e.opts.RecordIDs {
+			row = append(row, strconv.Itoa(rec.ID), strconv.Itoa(rec.ModID))
+		}
+		for _, c := range e.columns {
+			switch {
+			case c.table == "":
+				row = append(row, e.value(rec, c))
+			case e.opts.RelatedSets == ExpandRelatedSets:
+				var v string
+				if relatedRec.table == c.table {
+					v = e.value(relatedRec.rec, c)
+				}
+				row = append(row, v)
+			default:
+				var values []string
+				for _, r := range related[c.table] {
+					values = append(values, e.value(r, c))
+				}
+				row = append(row, strings.Join(values, e.separator()))
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (e *TableExporter) value(rec *Record, c exportColumn) string {
+	var data []string
+	for _, f := range rec.Fields {
+		if f.Name == c.def.Name {
+			data = f.Data
+			break
+		}
+	}
+
+	if c.rep > 0 {
+		if c.rep > len(data) {
+			return ""
+		}
+		return e.format(data[c.rep-1], c.def.Type)
+	}
+
+	values := make([]string, len(data))
+	for i, v := range data {
+		values[i] = e.format(v, c.def.Type)
+	}
+	return strings.Join(values, e.separator())
+}
+
+// format converts the value of the field type unless the
+// options are Raw, values which fail to parse are kept as they are
+func (e *TableExporter) format(v string, typ FieldType) string {
+	if e.opts.Raw || v == "" {
+		return v
+	}
+
+	switch typ {
+	case TypeNumber:
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return strconv.FormatFloat(n, 'f', -1, 64)
+		}
+	case TypeDate:
+		if t, err := time.Parse(DateFormat, v); err == nil {
+			return t.Format(exportDateFormat)
+		}
+	case TypeTimestamp:
+		if t, err := time.Parse(TimestampFormat, v); err == nil {
+			return t.Format(exportTimestampFormat)
+		}
+	}
+	return v
+}
+
+func (e *TableExporter) separator() string {
+	if e.opts.Separator == "" {
+		return "\n"
+	}
+	return e.opts.Separator
+}
+
+// NDJSONExporter writes every record as a line with
+// the JSON object of Record.JSONFields
+type NDJSONExporter struct {
+	w io.Writer
+}
+
+// NewNDJSONExporter creates NDJSONExporter
+func NewNDJSONExporter(w io.Writer) *NDJSONExporter {
+	return &NDJSONExporter{w: w}
+}
+
+// Write writes the records of the resultset
+func (e *NDJSONExporter) Write(rs *FMResultset) error {
+	if rs.Resultset == nil {
+		return nil
+	}
+
+	var b bytes.Buffer
+	for _, rec := range rs.Resultset.Records {
+		if rec.fieldsMap == nil {
+			rec.makeFieldsMap(false, rs.FieldDefinitions())
+		}
+		fields, err := json.Marshal(rec.fieldsMap)
+		if err != nil {
+			return fmt.Errorf("gofmcon.NDJSONExporter: record %d: %w", rec.ID, err)
+		}
+		b.Write(fields)
+		b.WriteByte('\n')
+	}
+
+	_, err := e.w.Write(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("gofmcon.NDJSONExporter: %w", err)
+	}
+	return nil
+}
+
+// Flush does nothing as NDJSONExporter doesn't buffer
+func (e *NDJSONExporter) Flush() error {
+	return nil
+}
+
+// Export sends the find query page by page, pageSize records at a time,
+// and writes every page with the exporter, so large found sets are never
+// held in memory. Max and Skip of the query are respected.
+// It returns the number of exported records
+func (fmc *FMConnector) Export(ctx context.Context, q *FMQuery, pageSize int, e Exporter) (int, error) {
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+
+	count := 0
+	skip := q.SkipRecords
+	for {
+		n := pageSize
+		if q.MaxRecords != fmAllRecords && q.MaxRecords-count < n {
+			n = q.MaxRecords - count
+		}
+
+		rs, err := fmc.Query(ctx, q.Clone().Max(n).Skip(skip))
+		if errors.Is(err, ErrRecordNotFound) {
+			// the header is still written from the metadata
+			rs.Resultset = nil
+		} else if err != nil {
+			return count, fmt.Errorf("gofmcon.Export: %w", err)
+		}
+
+		if err := e.Write(&rs); err != nil {
+			return count, fmt.Errorf("gofmcon.Export: %w", err)
+		}
+
+		fetched := 0
+		if rs.Resultset != nil {
+			fetched = len(rs.Resultset.Records)
+		}
+		count += fetched
+		skip += fetched
+		if fetched < n || (q.MaxRecords != fmAllRecords && count >= q.MaxRecords) {
+			break
+		}
+	}
+
+	if err := e.Flush(); err != nil {
+		return count, fmt.Errorf("gofmcon.Export: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,211 @@
+package gofmcon
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const exportXML = `<fmresultset><error code="0"/>
+<metadata>
+<field-definition name="name" result="text" max-repeat="1"/>
+<field-definition name="phone" result="text" max-repeat="2"/>
+<field-definition name="total" result="number" max-repeat="1"/>
+<field-definition name="since" result="date" max-repeat="1"/>
+<field-definition name="seen" result="timestamp" max-repeat="1"/>
+<relatedset-definition table="orders">
+<field-definition name="orders::item" result="text" max-repeat="1"/>
+<field-definition name="orders::qty" result="number" max-repeat="1"/>
+</relatedset-definition>
+</metadata>
+<resultset count="2" fetch-size="2">
+<record record-id="1" mod-id="4">
+<field name="name"><data>Jane "JJ"</data></field>
+<field name="phone"><data>1</data><data>2</data></field>
+<field name="total"><data>1200.50</data></field>
+<field name="since"><data>01/02/2020</data></field>
+<field name="seen"><data>01/02/2020 15:04:05</data></field>
+<relatedset count="2" table="orders">
+<record record-id="10" mod-id="1"><field name="orders::item"><data>pen</data></field><field name="orders::qty"><data>2</data></field></record>
+<record record-id="11" mod-id="1"><field name="orders::item"><data>ink</data></field><field name="orders::qty"><data>1</data></field></record>
+</relatedset>
+</record>
+<record record-id="2" mod-id="1">
+<field name="name"><data>tab	line
+break</data></field>
+<field name="phone"><data></data><data>3</data></field>
+<field name="total"><data>n/a</data></field>
+<field name="since"><data></data></field>
+<field name="seen"><data></data></field>
+<relatedset count="0" table="orders"></relatedset>
+</record>
+</resultset>
+</fmresultset>`
+
+func exportResultset(t *testing.T) *FMResultset {
+	var rs FMResultset
+	err := xml.Unmarshal([]byte(exportXML), &rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &rs
+}
+
+func TestCSVExporter(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ExportOptions
+		want string
+	}{
+		{
+			name: "default",
+			opts: ExportOptions{},
+			want: "name,phone,total,since,seen\n" +
+				"\"Jane \"\"JJ\"\"\",\"1\n2\",1200.5,2020-01-02,2020-01-02 15:04:05\n" +
+				"\"tab\tline\nbreak\",\"\n3\",n/a,,\n",
+		},
+		{
+			name: "split repetitions and join related sets",
+			opts: ExportOptions{Repetitions: SplitRepetitions, RelatedSets: JoinRelatedSets, Separator: "|", RecordIDs: true},
+			want: "recid,modid,name,phone(1),phone(2),total,since,seen,orders::item,orders::qty\n" +
+				"1,4,\"Jane \"\"JJ\"\"\",1,2,1200.5,2020-01-02,2020-01-02 15:04:05,pen|ink,2|1\n" +
+				"2,1,\"tab\tline\nbreak\",,3,n/a,,,,\n",
+		},
+		{
+			name: "first repetition, expanded related sets and raw values",
+			opts: ExportOptions{Repetitions: FirstRepetition, RelatedSets: ExpandRelatedSets, Raw: true, NoHeader: true},
+			want: "\"Jane \"\"JJ\"\"\",1,1200.50,01/02/2020,01/02/2020 15:04:05,pen,2\n" +
+				"\"Jane \"\"JJ\"\"\",1,1200.50,01/02/2020,01/02/2020 15:04:05,ink,1\n" +
+				"\"tab\tline\nbreak\",,n/a,,,,\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			e := NewCSVExporter(&b, tt.opts)
+			assert.NoError(t, e.Write(exportResultset(t)))
+			assert.NoError(t, e.Flush())
+			assert.Equal(t, tt.want, b.String())
+		})
+	}
+}
+
+func TestCSVExporterRelatedSets(t *testing.T) {
+	var rs FMResultset
+	err := xml.Unmarshal([]byte(`<fmresultset><error code="0"/>
+<metadata>
+<field-definition name="name" result="text" max-repeat="1"/>
+<relatedset-definition table="orders"><field-definition name="orders::total" result="number" max-repeat="1"/></relatedset-definition>
+<relatedset-definition table="payments"><field-definition name="payments::amount" result="number" max-repeat="1"/></relatedset-definition>
+</metadata>
+<resultset count="1" fetch-size="1">
+<record record-id="1" mod-id="1">
+<field name="name"><data>A</data></field>
+<relatedset count="1" table="orders">
+<record record-id="10" mod-id="1"><field name="orders::total"><data>10</data></field></record>
+</relatedset>
+<relatedset count="2" table="payments">
+<record record-id="20" mod-id="1"><field name="payments::amount"><data>3</data></field></record>
+<record record-id="21" mod-id="1"><field name="payments::amount"><data>4</data></field></record>
+</relatedset>
+</record>
+</resultset>
+</fmresultset>`), &rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	e := NewCSVExporter(&b, ExportOptions{RelatedSets: JoinRelatedSets, Separator: "|"})
+	assert.NoError(t, e.Write(&rs))
+	assert.NoError(t, e.Flush())
+	assert.Equal(t, "name,orders::total,payments::amount\nA,10,3|4\n", b.String())
+
+	b.Reset()
+	e = NewCSVExporter(&b, ExportOptions{RelatedSets: ExpandRelatedSets, NoHeader: true})
+	assert.NoError(t, e.Write(&rs))
+	assert.NoError(t, e.Flush())
+	assert.Equal(t, "A,10,\nA,,3\nA,,4\n", b.String())
+}
+
+func TestTSVExporter(t *testing.T) {
+	var b bytes.Buffer
+	e := NewTSVExporter(&b, ExportOptions{Repetitions: SplitRepetitions})
+	assert.NoError(t, e.Write(exportResultset(t)))
+	// the header is written only once
+	assert.NoError(t, e.Write(exportResultset(t)))
+	assert.NoError(t, e.Flush())
+
+	rows := "Jane \"JJ\"\t1\t2\t1200.5\t2020-01-02\t2020-01-02 15:04:05\n" +
+		"tab\\tline\\nbreak\t\t3\tn/a\t\t\n"
+	assert.Equal(t, "name\tphone(1)\tphone(2)\ttotal\tsince\tseen\n"+rows+rows, b.String())
+
+	e = NewTSVExporter(&b, ExportOptions{})
+	assert.Error(t, e.Write(&FMResultset{}))
+}
+
+func TestNDJSONExporter(t *testing.T) {
+	var b bytes.Buffer
+	e := NewNDJSONExporter(&b)
+	assert.NoError(t, e.Write(exportResultset(t)))
+	assert.NoError(t, e.Flush())
+
+	assert.Equal(t, ""+
+		`{"name":"Jane \"JJ\"","orders":[{"item":"pen","qty":2},{"item":"ink","qty":1}],"phone":["1","2"],"seen":"2020-01-02T15:04:05Z","since":"2020-01-02T00:00:00Z","total":1200.5}`+"\n"+
+		`{"name":"tab\tline\nbreak","orders":null,"phone":["","3"],"seen":"0001-01-01T00:00:00Z","since":"0001-01-01T00:00:00Z","total":null}`+"\n",
+		b.String())
+}
+
+func TestExport(t *testing.T) {
+	fake, conn := newFakeFileMaker(t)
+	for i := 1; i <= 5; i++ {
+		fake.add(map[string]string{"name": fmt.Sprintf("c%d", i)})
+	}
+	ctx := context.Background()
+
+	var b bytes.Buffer
+	n, err := conn.Export(ctx, NewFMQuery("db", "customers", FindAll), 2, NewCSVExporter(&b, ExportOptions{RecordIDs: true}))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, "recid,modid,name\n1,0,c1\n2,0,c2\n3,0,c3\n4,0,c4\n5,0,c5\n", b.String())
+	assert.Len(t, fake.queries, 3)
+	for i, q := range fake.queries {
+		assert.Equal(t, 2, q.MaxRecords)
+		assert.Equal(t, i*2, q.SkipRecords)
+	}
+
+	fake.queries = nil
+	b.Reset()
+	n, err = conn.Export(ctx, NewFMQuery("db", "customers", FindAll).Skip(1).Max(3), 2, NewNDJSONExporter(&b))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, `{"name":"c2"}`+"\n"+`{"name":"c3"}`+"\n"+`{"name":"c4"}`+"\n", b.String())
+	if assert.Len(t, fake.queries, 2) {
+		assert.Equal(t, 2, fake.queries[0].MaxRecords)
+		assert.Equal(t, 1, fake.queries[1].MaxRecords)
+		assert.Equal(t, 3, fake.queries[1].SkipRecords)
+	}
+
+	// the header is written from the metadata when nothing is found
+	conn = newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Replace(exportXML, `<error code="0"/>`, `<error code="401"/>`, 1)))
+	})
+	b.Reset()
+	n, err = conn.Export(ctx, NewFMQuery("db", "customers", Find).WithFields(Eq("x").For("name")), 0, NewCSVExporter(&b, ExportOptions{}))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, "name,phone,total,since,seen\n", b.String())
+
+	conn = newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<fmresultset><error code="802"/></fmresultset>`))
+	})
+	_, err = conn.Export(ctx, NewFMQuery("db", "customers", FindAll), 0, NewCSVExporter(&b, ExportOptions{}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,298 @@
+package gofmcon
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrExprNotExpressible is returned when an expression cannot be
+	// turned into a single FileMaker compound find
+	ErrExprNotExpressible = errors.New("expression cannot be expressed as FileMaker find requests")
+	// ErrExprMatchesAll is returned when an expression matches every record,
+	// use FindAll for that
+	ErrExprMatchesAll = errors.New("expression matches all records")
+	// ErrExprMatchesNone is returned when an expression never matches
+	ErrExprMatchesNone = errors.New("expression matches no records")
+)
+
+// Expr is a boolean find expression built with FieldExpr, AndExpr, OrExpr and NotExpr
+type Expr interface {
+	// dnf returns the expression in disjunctive normal form
+	dnf() []exprTerm
+}
+
+type fieldExpr struct {
+	field FMQueryField
+}
+
+type andExpr struct {
+	exprs []Expr
+}
+
+type orExpr struct {
+	exprs []Expr
+}
+
+type notExpr struct {
+	expr Expr
+}
+
+// FieldExpr matches records where the field satisfies the condition
+func FieldExpr(name string, cond FMCondition) Expr {
+	return fieldExpr{field: cond.For(name)}
+}
+
+// QueryFieldExpr matches records where the field matches its value and operator
+func QueryFieldExpr(f FMQueryField) Expr {
+	return fieldExpr{field: f}
+}
+
+// AndExpr matches records matching all the expressions
+func AndExpr(exprs ...Expr) Expr {
+	return andExpr{exprs: exprs}
+}
+
+// OrExpr matches records matching any of the expressions
+func OrExpr(exprs ...Expr) Expr {
+	return orExpr{exprs: exprs}
+}
+
+// NotExpr matches records not matching the expression
+func NotExpr(e Expr) Expr {
+	return notExpr{expr: e}
+}
+
+// exprTerm is a conjunction of fields matching and omit requests not matching
+type exprTerm struct {
+	pos  []FMQueryField
+	negs [][]FMQueryField
+}
+
+func (e fieldExpr) dnf() []exprTerm {
+	return []exprTerm{{pos: []FMQueryField{e.field}}}
+}
+
+func (e andExpr) dnf() []exprTerm {
+	terms := []exprTerm{{}}
+	for _, sub := range e.exprs {
+		terms = productTerms(terms, sub.dnf())
+	}
+	return terms
+}
+
+func (e orExpr) dnf() []exprTerm {
+	var terms []exprTerm
+	for _, sub := range e.exprs {
+		terms = append(terms, sub.dnf()...)
+	}
+	return terms
+}
+
+// dnf of not applies De Morgan's laws: not (p1 and not n1 or ...) is
+// (not p1 or n1) and ..., where not p1 is an omit request
+func (e notExpr) dnf() []exprTerm {
+	terms := []exprTerm{{}}
+	for _, t := range e.expr.dnf() {
+		var negated []exprTerm
+		if len(t.pos) > 0 {
+			negated = append(negated, exprTerm{negs: [][]FMQueryField{t.pos}})
+		}
+		for _, n := range t.negs {
+			negated = append(negated, exprTerm{pos: n})
+		}
+		terms = productTerms(terms, negated)
+	}
+	return terms
+}
+
+func productTerms(a, b []exprTerm) []exprTerm {
+	var terms []exprTerm
+	for _, ta := range a {
+		for _, tb := range b {
+			terms = append(terms, exprTerm{
+				pos:  uniqueFields(append(append([]FMQueryField(nil), ta.pos...), tb.pos...)),
+				negs: uniqueRequests(append(append([][]FMQueryField(nil), ta.negs...), tb.negs...)),
+			})
+		}
+	}
+	return terms
+}
+
+func fieldKey(f FMQueryField) string {
+	return fmt.Sprintf("%q %q %q %q %t", f.Name, f.Op, f.Value, f.ValueTo, f.Raw)
+}
+
+func fieldsKey(fields []FMQueryField) string {
+	keys := make([]string, len(fields))
+	for i, f := range fields {
+		keys[i] = fieldKey(f)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, "\n")
+}
+
+func uniqueFields(fields []FMQueryField) []FMQueryField {
+	seen := map[string]bool{}
+	var unique []FMQueryField
+	for _, f := range fields {
+		key := fieldKey(f)
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, f)
+		}
+	}
+	return unique
+}
+
+func uniqueRequests(requests [][]FMQueryField) [][]FMQueryField {
+	seen := map[string]bool{}
+	var unique [][]FMQueryField
+	for _, r := range requests {
+		key := fieldsKey(r)
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, r)
+		}
+	}
+	return unique
+}
+
+// subsumes reports whether every record matching t also matches other
+func (t exprTerm) subsumes(other exprTerm) bool {
+	return keysSubset(fieldKeys(t.pos), fieldKeys(other.pos)) &&
+		keysSubset(requestKeys(t.negs), requestKeys(other.negs))
+}
+
+func fieldKeys(fields []FMQueryField) map[string]bool {
+	keys := map[string]bool{}
+	for _, f := range fields {
+		keys[fieldKey(f)] = true
+	}
+	return keys
+}
+
+func requestKeys(requests [][]FMQueryField) map[string]bool {
+	keys := map[string]bool{}
+	for _, r := range requests {
+		keys[fieldsKey(r)] = true
+	}
+	return keys
+}
+
+func keysSubset(a, b map[string]bool) bool {
+	for k := range a {
+		if !b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeExpr turns the expression into FileMaker find and omit requests.
+//
+// FileMaker processes requests in order: a find request adds matching
+// records to the found set and an omit request removes them from it.
+// The expression is brought to disjunctive normal form, and its terms are
+// grouped by the omit requests they need. Groups are emitted from the one
+// with the most omit requests, each followed by its omit requests, which
+// is correct as long as the omit requests of every group contain those of
+// the next one, e.g. (A and (B or C)) and not D becomes (A,B);(A,C);!(D).
+// Otherwise ErrExprNotExpressible is returned
+func NormalizeExpr(e Expr) ([]FMQueryFieldGroup, error) {
+	terms := e.dnf()
+
+	// drop terms which are covered by other terms
+	var reduced []exprTerm
+	for i, t := range terms {
+		covered := false
+		for j, other := range terms {
+			if i == j || !other.subsumes(t) {
+				continue
+			}
+			// of two equal terms keep the first one
+			if !t.subsumes(other) || j < i {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			reduced = append(reduced, t)
+		}
+	}
+
+	if len(reduced) == 0 {
+		return nil, ErrExprMatchesNone
+	}
+
+	type termGroup struct {
+		negs  [][]FMQueryField
+		keys  map[string]bool
+		terms []exprTerm
+	}
+	var groups []*termGroup
+	byKey := map[string]*termGroup{}
+	for _, t := range reduced {
+		if len(t.pos) == 0 && len(t.negs) == 0 {
+			return nil, ErrExprMatchesAll
+		}
+		key := strings.Join(sortedKeys(requestKeys(t.negs)), "\n\n")
+		g, ok := byKey[key]
+		if !ok {
+			g = &termGroup{negs: t.negs, keys: requestKeys(t.negs)}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.terms = append(g.terms, t)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].negs) > len(groups[j].negs)
+	})
+
+	var fieldGroups []FMQueryFieldGroup
+	for i, g := range groups {
+		if i > 0 && !keysSubset(g.keys, groups[i-1].keys) {
+			return nil, ErrExprNotExpressible
+		}
+		for _, t := range g.terms {
+			if len(t.pos) == 0 {
+				// all records but the omitted ones, FileMaker starts from
+				// all records when the first request is an omit request
+				if i > 0 {
+					return nil, ErrExprNotExpressible
+				}
+				continue
+			}
+			fieldGroups = append(fieldGroups, FMQueryFieldGroup{Op: And, Fields: t.pos})
+		}
+		for _, n := range g.negs {
+			fieldGroups = append(fieldGroups, FMQueryFieldGroup{Op: Not, Fields: n})
+		}
+	}
+
+	return fieldGroups, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WithExpr replaces the groups of fields of the find request
+// with the normalized expression, see NormalizeExpr
+func (q *FMQuery) WithExpr(e Expr) *FMQuery {
+	groups, err := NormalizeExpr(e)
+	if err != nil {
+		q.setErr(err)
+		return q
+	}
+	q.QueryFields = groups
+	return q
+}
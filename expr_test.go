@@ -0,0 +1,135 @@
+package gofmcon
+
+import (
+	"errors"
+	"math/rand"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// evalExpr evaluates the expression for a record where the fields
+// in truth are the ones matching
+func evalExpr(e Expr, truth map[string]bool) bool {
+	switch ex := e.(type) {
+	case fieldExpr:
+		return truth[ex.field.Name]
+	case andExpr:
+		for _, sub := range ex.exprs {
+			if !evalExpr(sub, truth) {
+				return false
+			}
+		}
+		return true
+	case orExpr:
+		for _, sub := range ex.exprs {
+			if evalExpr(sub, truth) {
+				return true
+			}
+		}
+		return false
+	case notExpr:
+		return !evalExpr(ex.expr, truth)
+	}
+	panic("unknown expression")
+}
+
+// evalRequests tells whether FileMaker would find the record
+// processing the requests in order
+func evalRequests(groups []FMQueryFieldGroup, truth map[string]bool) bool {
+	found := len(groups) > 0 && groups[0].Op == Not
+	for _, g := range groups {
+		all, any := true, false
+		for _, f := range g.Fields {
+			all = all && truth[f.Name]
+			any = any || truth[f.Name]
+		}
+		switch g.Op {
+		case And:
+			found = found || all
+		case Or:
+			found = found || any
+		case Not:
+			found = found && !all
+		}
+	}
+	return found
+}
+
+var exprAtoms = []string{"a", "b", "c", "d"}
+
+func assertEquivalent(t *testing.T, e Expr, groups []FMQueryFieldGroup) {
+	for mask := 0; mask < 1<<len(exprAtoms); mask++ {
+		truth := map[string]bool{}
+		for i, name := range exprAtoms {
+			truth[name] = mask&(1<<i) != 0
+		}
+		assert.Equal(t, evalExpr(e, truth), evalRequests(groups, truth), "assignment %v", truth)
+	}
+}
+
+func atom(name string) Expr {
+	return FieldExpr(name, Eq("1"))
+}
+
+func TestNormalizeExpr(t *testing.T) {
+	a, b, c, d := atom("a"), atom("b"), atom("c"), atom("d")
+
+	groups, err := NormalizeExpr(AndExpr(a, OrExpr(b, c), NotExpr(d)))
+	assert.NoError(t, err)
+	q := NewFMQuery("db", "layout", Find)
+	q.QueryFields = groups
+	values, _ := url.ParseQuery(q.compoundQueryString())
+	assert.Equal(t, "(q1,q2);(q3,q4);!(q5)", values.Get("-query"))
+
+	exprs := []Expr{
+		AndExpr(a, OrExpr(b, c), NotExpr(d)),
+		NotExpr(OrExpr(a, b)),
+		NotExpr(AndExpr(a, NotExpr(b))),
+		OrExpr(AndExpr(a, NotExpr(b), NotExpr(c)), AndExpr(d, NotExpr(b)), c),
+		AndExpr(OrExpr(a, b), OrExpr(c, d)),
+		OrExpr(NotExpr(a), AndExpr(b, NotExpr(a), NotExpr(c))),
+	}
+	for _, e := range exprs {
+		groups, err := NormalizeExpr(e)
+		assert.NoError(t, err)
+		assertEquivalent(t, e, groups)
+	}
+
+	_, err = NormalizeExpr(OrExpr(AndExpr(a, NotExpr(b)), AndExpr(c, NotExpr(d))))
+	assert.True(t, errors.Is(err, ErrExprNotExpressible))
+	_, err = NormalizeExpr(AndExpr())
+	assert.True(t, errors.Is(err, ErrExprMatchesAll))
+	_, err = NormalizeExpr(OrExpr())
+	assert.True(t, errors.Is(err, ErrExprMatchesNone))
+}
+
+func randomExpr(r *rand.Rand, depth int) Expr {
+	if depth == 0 || r.Intn(4) == 0 {
+		return atom(exprAtoms[r.Intn(len(exprAtoms))])
+	}
+	switch r.Intn(3) {
+	case 0:
+		return NotExpr(randomExpr(r, depth-1))
+	case 1:
+		return AndExpr(randomExpr(r, depth-1), randomExpr(r, depth-1))
+	default:
+		return OrExpr(randomExpr(r, depth-1), randomExpr(r, depth-1))
+	}
+}
+
+func TestNormalizeExprEquivalence(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var normalized int
+	for i := 0; i < 500; i++ {
+		e := randomExpr(r, 4)
+		groups, err := NormalizeExpr(e)
+		if err != nil {
+			continue
+		}
+		normalized++
+		assertEquivalent(t, e, groups)
+	}
+	assert.Greater(t, normalized, 250)
+}
@@ -0,0 +1,514 @@
+package gofmcon
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FilterSyntaxError is returned by ParseFilter for a malformed filter.
+// Pos is the 1-based position of the character the error was found at
+type FilterSyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *FilterSyntaxError) Error() string {
+	return fmt.Sprintf("filter syntax error at position %d: %s", e.Pos, e.Msg)
+}
+
+type filterTokenKind int
+
+const (
+	tokEOF filterTokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+	pos  int
+	// quoted identifiers are never keywords
+	quoted bool
+}
+
+// filterOps maps operators of the filter language to field operators,
+// != is handled separately as it negates Equal
+var filterOps = map[string]FMFieldOp{
+	"=":  Equal,
+	"==": Equal,
+	">":  GreaterThan,
+	">=": GreaterThanEqual,
+	"<":  LessThan,
+	"<=": LessThanEqual,
+	"^=": BeginsWith,
+	"$=": EndsWith,
+	"*=": Contains,
+	"~=": WholeWord,
+}
+
+type filterLexer struct {
+	src string
+	pos int
+}
+
+func (l *filterLexer) errorf(pos int, format string, args ...interface{}) error {
+	return &FilterSyntaxError{Pos: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (l *filterLexer) next() (filterToken, error) {
+	for l.pos < len(l.src) {
+		r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		l.pos += size
+	}
+	if l.pos >= len(l.src) {
+		return filterToken{kind: tokEOF, pos: l.pos}, nil
+	}
+
+	start := l.pos
+	c := l.src[l.pos]
+	switch {
+	case c == '(':
+		l.pos++
+		return filterToken{kind: tokLParen, text: "(", pos: start}, nil
+	case c == ')':
+		l.pos++
+		return filterToken{kind: tokRParen, text: ")", pos: start}, nil
+	case c == '"' || c == '`':
+		text, err := l.quoted(c)
+		if err != nil {
+			return filterToken{}, err
+		}
+		kind := tokString
+		if c == '`' {
+			kind = tokIdent
+		}
+		return filterToken{kind: kind, text: text, pos: start, quoted: true}, nil
+	case strings.ContainsRune("=!<>^$*~", rune(c)):
+		l.pos++
+		if l.pos < len(l.src) && l.src[l.pos] == '=' {
+			l.pos++
+		}
+		op := l.src[start:l.pos]
+		if _, ok := filterOps[op]; !ok && op != "!=" {
+			return filterToken{}, l.errorf(start, "unknown operator %q", op)
+		}
+		return filterToken{kind: tokOp, text: op, pos: start}, nil
+	case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		l.pos++
+		for l.pos < len(l.src) && (l.src[l.pos] == '.' || (l.src[l.pos] >= '0' && l.src[l.pos] <= '9')) {
+			l.pos++
+		}
+		if strings.IndexFunc(l.src[start:l.pos], unicode.IsDigit) < 0 {
+			return filterToken{}, l.errorf(start, "malformed number %q", l.src[start:l.pos])
+		}
+		return filterToken{kind: tokNumber, text: l.src[start:l.pos], pos: start}, nil
+	}
+
+	for l.pos < len(l.src) {
+		r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+		if !isFilterIdentRune(r) {
+			break
+		}
+		l.pos += size
+	}
+	if l.pos == start {
+		return filterToken{}, l.errorf(start, "unexpected character %q", l.src[start:start+1])
+	}
+	return filterToken{kind: tokIdent, text: l.src[start:l.pos], pos: start}, nil
+}
+
+func (l *filterLexer) quoted(quote byte) (string, error) {
+	start := l.pos
+	l.pos++
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\\' && l.pos+1 < len(l.src):
+			b.WriteByte(l.src[l.pos+1])
+			l.pos += 2
+		case c == quote:
+			l.pos++
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+	return "", l.errorf(start, "unterminated %c", quote)
+}
+
+func isFilterIdentRune(r rune) bool {
+	return r == '_' || r == ':' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+type filterParser struct {
+	lex *filterLexer
+	tok filterToken
+}
+
+func (p *filterParser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *filterParser) keyword(kw string) bool {
+	return p.tok.kind == tokIdent && !p.tok.quoted && strings.EqualFold(p.tok.text, kw)
+}
+
+func (p *filterParser) expect(kw string) error {
+	if !p.keyword(kw) {
+		return p.lex.errorf(p.tok.pos, "expected %s, got %s", kw, p.tok.describe())
+	}
+	return p.advance()
+}
+
+func (t filterToken) describe() string {
+	if t.kind == tokEOF {
+		return "end of filter"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// ParseFilter parses a filter such as
+//
+//	status = "open" AND (total > 100 OR vip = 1) AND NOT region ^= "EU"
+//
+// into an expression. Conditions are a field name followed by one of
+// = (equal), != (not equal), >, >=, <, <=, ^= (begins with), $= (ends with),
+// *= (contains), ~= (whole word) and a quoted string or a number, or by
+// BETWEEN a AND b, IS EMPTY, IS NOT EMPTY, IS DUPLICATE, IS TODAY, IS INVALID,
+// MATCHES "value" for the FileMaker default matching or RAW "value" for
+// FileMaker find syntax. Conditions are combined with AND, OR, NOT and
+// parentheses; keywords are case insensitive. Field names with spaces or
+// other special characters are quoted with backticks
+func ParseFilter(s string) (Expr, error) {
+	p := &filterParser{lex: &filterLexer{src: s}}
+	err := p.advance()
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.lex.errorf(p.tok.pos, "unexpected %s", p.tok.describe())
+	}
+
+	return e, nil
+}
+
+func (p *filterParser) parseOr() (Expr, error) {
+	var exprs []Expr
+	for {
+		e, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+		if !p.keyword("or") {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return OrExpr(exprs...), nil
+}
+
+func (p *filterParser) parseAnd() (Expr, error) {
+	var exprs []Expr
+	for {
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+		if !p.keyword("and") {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return AndExpr(exprs...), nil
+}
+
+func (p *filterParser) parseUnary() (Expr, error) {
+	switch {
+	case p.keyword("not"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return NotExpr(e), nil
+	case p.tok.kind == tokLParen:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.lex.errorf(p.tok.pos, "expected ), got %s", p.tok.describe())
+		}
+		return e, p.advance()
+	default:
+		return p.parseCondition()
+	}
+}
+
+func (p *filterParser) parseCondition() (Expr, error) {
+	if p.tok.kind != tokIdent {
+		return nil, p.lex.errorf(p.tok.pos, "expected field name, got %s", p.tok.describe())
+	}
+	name := p.tok.text
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.tok.kind == tokOp:
+		op := p.tok.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if op == "!=" {
+			return NotExpr(QueryFieldExpr(FMQueryField{Name: name, Op: Equal, Value: value})), nil
+		}
+		return QueryFieldExpr(FMQueryField{Name: name, Op: filterOps[op], Value: value}), nil
+	case p.keyword("between"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		from, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("and"); err != nil {
+			return nil, err
+		}
+		to, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return QueryFieldExpr(FMQueryField{Name: name, Op: InRange, Value: from, ValueTo: to}), nil
+	case p.keyword("matches"), p.keyword("raw"):
+		raw := p.keyword("raw")
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return QueryFieldExpr(FMQueryField{Name: name, Value: value, Raw: raw}), nil
+	case p.keyword("is"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		negate := p.keyword("not")
+		if negate {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		var op FMFieldOp
+		switch {
+		case p.keyword("empty"):
+			op = Empty
+			if negate {
+				op = NonEmpty
+				negate = false
+			}
+		case p.keyword("duplicate"):
+			op = Duplicated
+		case p.keyword("today"):
+			op = IsToday
+		case p.keyword("invalid"):
+			op = InvalidValue
+		default:
+			return nil, p.lex.errorf(p.tok.pos, "expected EMPTY, DUPLICATE, TODAY or INVALID, got %s", p.tok.describe())
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		e := QueryFieldExpr(FMQueryField{Name: name, Op: op})
+		if negate {
+			return NotExpr(e), nil
+		}
+		return e, nil
+	default:
+		return nil, p.lex.errorf(p.tok.pos, "expected operator after field %s, got %s", name, p.tok.describe())
+	}
+}
+
+func (p *filterParser) parseValue() (string, error) {
+	if p.tok.kind != tokString && p.tok.kind != tokNumber {
+		return "", p.lex.errorf(p.tok.pos, "expected quoted string or number, got %s", p.tok.describe())
+	}
+	value := p.tok.text
+	return value, p.advance()
+}
+
+// WithFilter replaces the groups of fields of the find request
+// with the parsed filter, see ParseFilter and NormalizeExpr
+func (q *FMQuery) WithFilter(filter string) *FMQuery {
+	e, err := ParseFilter(filter)
+	if err != nil {
+		q.setErr(err)
+		return q
+	}
+	return q.WithExpr(e)
+}
+
+// FilterString renders groups of fields of the find request as a filter
+// ParseFilter understands, for example to log the query
+func (q *FMQuery) FilterString() string {
+	return FormatFilter(q.QueryFields)
+}
+
+// FormatFilter renders groups of fields as a filter ParseFilter understands.
+// The requests are folded in the order FileMaker processes them,
+// so the filter has the same meaning as the requests
+func FormatFilter(groups []FMQueryFieldGroup) string {
+	var filter string
+	var hasOr bool
+	for _, g := range groups {
+		if len(g.Fields) == 0 {
+			continue
+		}
+		var conds []string
+		for _, f := range g.Fields {
+			conds = append(conds, formatFilterField(f))
+		}
+
+		switch g.Op {
+		case Not:
+			omit := strings.Join(conds, " AND ")
+			if len(conds) > 1 {
+				omit = "(" + omit + ")"
+			}
+			switch {
+			case filter == "":
+				filter = "NOT " + omit
+			case hasOr:
+				filter = "(" + filter + ") AND NOT " + omit
+			default:
+				filter = filter + " AND NOT " + omit
+			}
+			hasOr = false
+		default:
+			sep := " AND "
+			if g.Op == Or {
+				sep = " OR "
+			}
+			find := strings.Join(conds, sep)
+			if filter == "" {
+				filter = find
+				hasOr = g.Op == Or && len(conds) > 1
+			} else {
+				filter = filter + " OR " + find
+				hasOr = true
+			}
+		}
+	}
+	return filter
+}
+
+// filterKeywords must be quoted when used as field names
+var filterKeywords = []string{"and", "or", "not", "between", "is", "matches", "raw"}
+
+func formatFilterField(f FMQueryField) string {
+	return FilterFieldName(f.Name) + formatFilterCondition(f)
+}
+
+// FilterFieldName returns the field name as it is written in a filter,
+// quoted with backticks when it isn't a plain identifier
+func FilterFieldName(name string) string {
+	plain := name != "" &&
+		(unicode.IsLetter([]rune(name)[0]) || name[0] == '_') &&
+		strings.IndexFunc(name, func(r rune) bool { return !isFilterIdentRune(r) }) < 0
+	for _, kw := range filterKeywords {
+		plain = plain && !strings.EqualFold(name, kw)
+	}
+	if plain {
+		return name
+	}
+	return "`" + strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(name) + "`"
+}
+
+func formatFilterCondition(f FMQueryField) string {
+	if f.Raw {
+		return " RAW " + quoteFilterValue(f.valueWithOp())
+	}
+
+	switch f.Op {
+	case Equal:
+		return " = " + quoteFilterValue(f.Value)
+	case Contains:
+		return " *= " + quoteFilterValue(f.Value)
+	case BeginsWith:
+		return " ^= " + quoteFilterValue(f.Value)
+	case EndsWith:
+		return " $= " + quoteFilterValue(f.Value)
+	case GreaterThan:
+		return " > " + quoteFilterValue(f.Value)
+	case GreaterThanEqual:
+		return " >= " + quoteFilterValue(f.Value)
+	case LessThan:
+		return " < " + quoteFilterValue(f.Value)
+	case LessThanEqual:
+		return " <= " + quoteFilterValue(f.Value)
+	case WholeWord:
+		return " ~= " + quoteFilterValue(f.Value)
+	case InRange:
+		return " BETWEEN " + quoteFilterValue(f.Value) + " AND " + quoteFilterValue(f.ValueTo)
+	case Empty:
+		return " IS EMPTY"
+	case NonEmpty:
+		return " IS NOT EMPTY"
+	case Duplicated:
+		return " IS DUPLICATE"
+	case IsToday:
+		return " IS TODAY"
+	case InvalidValue:
+		return " IS INVALID"
+	default:
+		return " MATCHES " + quoteFilterValue(f.Value)
+	}
+}
+
+func quoteFilterValue(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+}
@@ -0,0 +1,90 @@
+package gofmcon
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFilter(t *testing.T) {
+	e, err := ParseFilter(`status = "open" AND (total > 100 OR vip = 1) and not region ^= "EU"`)
+	assert.NoError(t, err)
+
+	groups, err := NormalizeExpr(e)
+	assert.NoError(t, err)
+	assert.Equal(t, []FMQueryFieldGroup{
+		{Op: And, Fields: []FMQueryField{{Name: "status", Op: Equal, Value: "open"}, {Name: "total", Op: GreaterThan, Value: "100"}}},
+		{Op: And, Fields: []FMQueryField{{Name: "status", Op: Equal, Value: "open"}, {Name: "vip", Op: Equal, Value: "1"}}},
+		{Op: Not, Fields: []FMQueryField{{Name: "region", Op: BeginsWith, Value: "EU"}}},
+	}, groups)
+
+	e, err = ParseFilter("`first name` *= \"Jo \\\"Jr\\\"\" AND created BETWEEN \"1/1/2020\" AND \"12/31/2020\" AND " +
+		"email IS NOT EMPTY AND note RAW \"=\" AND `or` != -1.5 AND a::b IS TODAY")
+	assert.NoError(t, err)
+	groups, err = NormalizeExpr(e)
+	assert.NoError(t, err)
+	assert.Equal(t, []FMQueryFieldGroup{
+		{Op: And, Fields: []FMQueryField{
+			{Name: "first name", Op: Contains, Value: `Jo "Jr"`},
+			{Name: "created", Op: InRange, Value: "1/1/2020", ValueTo: "12/31/2020"},
+			{Name: "email", Op: NonEmpty},
+			{Name: "note", Value: "=", Raw: true},
+			{Name: "a::b", Op: IsToday},
+		}},
+		{Op: Not, Fields: []FMQueryField{{Name: "or", Op: Equal, Value: "-1.5"}}},
+	}, groups)
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	cases := map[string]int{
+		`status = `:         10,
+		`status "open"`:     8,
+		`(a = 1 OR b = 2`:   16,
+		`a = 1 AND`:         10,
+		`a ! 1`:             3,
+		`a = "unterminated`: 5,
+		`a = 1 b = 2`:       7,
+		`a BETWEEN 1 OR 2`:  13,
+		`a IS FULL`:         6,
+	}
+	for filter, pos := range cases {
+		_, err := ParseFilter(filter)
+		var syntaxErr *FilterSyntaxError
+		if assert.True(t, errors.As(err, &syntaxErr), filter) {
+			assert.Equal(t, pos, syntaxErr.Pos, "%s: %s", filter, err)
+		}
+	}
+}
+
+func TestFormatFilterRoundTrip(t *testing.T) {
+	filters := []string{
+		`status = "open" AND (total > 100 OR vip = "1") AND NOT region ^= "EU"`,
+		`NOT (a = "1" AND b = "2") OR c IS EMPTY`,
+		"`weird name` ~= \"x\\\\y\" OR d BETWEEN \"1\" AND \"2\"",
+	}
+	for _, filter := range filters {
+		e, err := ParseFilter(filter)
+		assert.NoError(t, err)
+		q := NewFMQuery("db", "layout", Find).WithExpr(e)
+		assert.NoError(t, q.Err())
+
+		printed := q.FilterString()
+		reparsed := NewFMQuery("db", "layout", Find).WithFilter(printed)
+		assert.NoError(t, reparsed.Err(), printed)
+		assert.Equal(t, q.QueryString(), reparsed.QueryString(), printed)
+	}
+
+	assert.Equal(t, `(a = "1" OR b = "2") AND NOT c = "3"`, FormatFilter([]FMQueryFieldGroup{
+		{Op: Or, Fields: []FMQueryField{{Name: "a", Op: Equal, Value: "1"}, {Name: "b", Op: Equal, Value: "2"}}},
+		{Op: Not, Fields: []FMQueryField{{Name: "c", Op: Equal, Value: "3"}}},
+	}))
+}
+
+func TestFilterFieldName(t *testing.T) {
+	assert.Equal(t, "orders::total", FilterFieldName("orders::total"))
+	assert.Equal(t, "`first name`", FilterFieldName("first name"))
+	assert.Equal(t, "`and`", FilterFieldName("and"))
+	assert.Equal(t, "`1st`", FilterFieldName("1st"))
+	assert.Equal(t, "`a\\`b`", FilterFieldName("a`b"))
+}
@@ -1,5 +1,21 @@
 package gofmcon
 
+import "errors"
+
+var (
+	// ErrRecordNotFound matches FileMaker errors 101 (record is missing)
+	// and 401 (no records match the request)
+	ErrRecordNotFound = errors.New("record not found")
+	// ErrRecordModified matches FileMaker error 306, returned when
+	// the record was changed since the mod id sent with -edit
+	ErrRecordModified = errors.New("record was modified")
+	// ErrRecordInUse matches FileMaker error 301
+	ErrRecordInUse = errors.New("record is in use by another user")
+	// ErrDuplicateKey is returned by Upsert when more
+	// than one record matches the key fields
+	ErrDuplicateKey = errors.New("more than one record matches the key")
+)
+
 // FileMakerErrorCodes are all error codes taken from FileMaker official documentation
 var FileMakerErrorCodes = map[int]string{
 	-1:   "Unknown error",
@@ -3,8 +3,10 @@ package gofmcon
 import (
 	"fmt"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -52,10 +54,31 @@ func (so FMSortOrder) String() string {
 	return string(so)
 }
 
+// fmMaxSortFields is the number of sort fields FileMaker accepts
+const fmMaxSortFields = 9
+
 // FMSortField is a field that should be sorted during the query
 type FMSortField struct {
 	Name  string
 	Order FMSortOrder
+	// ValueList is the name of the value list the field is sorted by,
+	// required for Custom order
+	ValueList string
+}
+
+// CustomSortField creates FMSortField sorting the field
+// in the order of values in the value list
+func CustomSortField(name, valueList string) FMSortField {
+	return FMSortField{Name: name, Order: Custom, ValueList: valueList}
+}
+
+// sortOrderString is the value of -sortorder, which is
+// the name of the value list for custom order
+func (sf FMSortField) sortOrderString() string {
+	if sf.Order == Custom {
+		return url.QueryEscape(sf.ValueList)
+	}
+	return sf.Order.String()
 }
 
 // FMFieldOp is type of operator for a FMField
@@ -78,38 +101,226 @@ const (
 	LessThan FMFieldOp = "lt"
 	// LessThanEqual -lte
 	LessThanEqual FMFieldOp = "lte"
+	// InRange matches values from Value to ValueTo inclusive, ...
+	InRange FMFieldOp = "range"
+	// Empty matches empty fields, =
+	Empty FMFieldOp = "empty"
+	// NonEmpty matches fields with any value, *
+	NonEmpty FMFieldOp = "notempty"
+	// Duplicated matches values occurring in more than one record, !
+	Duplicated FMFieldOp = "dup"
+	// IsToday matches today's date, //
+	IsToday FMFieldOp = "today"
+	// InvalidValue matches values of a wrong type, such as text in a date field, ?
+	InvalidValue FMFieldOp = "invalid"
+	// WholeWord matches fields containing Value as a whole word, =
+	WholeWord FMFieldOp = "word"
 )
 
-// FMQueryField is a field used in FMQuery
+// FMQueryField is a field used in FMQuery.
+// FileMaker find symbols in the Value of a find request, such as *, @, ==
+// or ..., are escaped so they are matched literally. Set Raw to send
+// the Value as it is, for example to use FileMaker find syntax directly
 type FMQueryField struct {
 	Name  string
 	Value string
-	Op    FMFieldOp
+	// ValueTo is the upper bound of InRange
+	ValueTo string
+	Op      FMFieldOp
+	Raw     bool
+
+	// err is the error of formatting the operands of FMCondition
+	err error
 }
 
 func (qf *FMQueryField) valueWithOp() string {
+	value, valueTo := qf.Value, qf.ValueTo
+	if !qf.Raw {
+		value = escapeFindValue(value)
+		valueTo = escapeFindValue(valueTo)
+	}
+
 	switch qf.Op {
 	case Equal:
-		return "==" + qf.Value
+		return "==" + value
 	case Contains:
-		return "==*" + qf.Value + "*"
+		return "==*" + value + "*"
 	case BeginsWith:
-		return "==" + qf.Value + "*"
+		return "==" + value + "*"
 	case EndsWith:
-		return "==*" + qf.Value
+		return "==*" + value
 	case GreaterThan:
-		return ">" + qf.Value
+		return ">" + value
 	case GreaterThanEqual:
-		return ">=" + qf.Value
+		return ">=" + value
 	case LessThan:
-		return "<" + qf.Value
+		return "<" + value
 	case LessThanEqual:
-		return "<=" + qf.Value
+		return "<=" + value
+	case InRange:
+		return value + "..." + valueTo
+	case Empty:
+		return "="
+	case NonEmpty:
+		return "*"
+	case Duplicated:
+		return "!"
+	case IsToday:
+		return "//"
+	case InvalidValue:
+		return "?"
+	case WholeWord:
+		return "=" + value
 	default:
-		return qf.Value
+		return value
 	}
 }
 
+// escapeFindValue escapes FileMaker find symbols with a backslash.
+// Dots and slashes are only special as ranges (...) and today (//),
+// so a single one, as in a number or a date, is kept as it is
+func escapeFindValue(v string) string {
+	var b strings.Builder
+	runes := []rune(v)
+	for i, r := range runes {
+		switch r {
+		case '\\', '"', '*', '@', '#', '~', '!', '=', '<', '>', '?', '≤', '≥', '≠', '…':
+			b.WriteRune('\\')
+		case '.', '/':
+			if (i > 0 && runes[i-1] == r) || (i+1 < len(runes) && runes[i+1] == r) {
+				b.WriteRune('\\')
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// FMCondition is an operator with its operands,
+// which can be applied to any field with For
+type FMCondition struct {
+	Op      FMFieldOp
+	Value   string
+	ValueTo string
+
+	// err is reported by Validate of the query using the condition
+	err error
+}
+
+// For creates FMQueryField matching the condition
+func (c FMCondition) For(name string) FMQueryField {
+	return FMQueryField{Name: name, Op: c.Op, Value: c.Value, ValueTo: c.ValueTo, err: c.err}
+}
+
+// Err returns the error of formatting the operands,
+// for example when the value is a struct
+func (c FMCondition) Err() error {
+	return c.err
+}
+
+// Eq matches values equal to v
+func Eq(v interface{}) FMCondition {
+	return newCondition(Equal, v)
+}
+
+// Gt matches values greater than v
+func Gt(v interface{}) FMCondition {
+	return newCondition(GreaterThan, v)
+}
+
+// Gte matches values greater than or equal to v
+func Gte(v interface{}) FMCondition {
+	return newCondition(GreaterThanEqual, v)
+}
+
+// Lt matches values less than v
+func Lt(v interface{}) FMCondition {
+	return newCondition(LessThan, v)
+}
+
+// Lte matches values less than or equal to v
+func Lte(v interface{}) FMCondition {
+	return newCondition(LessThanEqual, v)
+}
+
+// Between matches values from a to b inclusive. Bounds can be
+// strings, numbers or time.Time, which is formatted as a date if it
+// has no time of day and as a timestamp otherwise
+func Between(a, b interface{}) FMCondition {
+	c := newCondition(InRange, a)
+	to, err := formatBound(b)
+	if c.err == nil {
+		c.err = err
+	}
+	c.ValueTo = to
+	return c
+}
+
+// now is the clock of LastDays and NextDays, replaced in tests
+var now = time.Now
+
+// LastDays matches dates from n days ago till today inclusive
+func LastDays(n int) FMCondition {
+	today := truncateToDate(now())
+	return Between(today.AddDate(0, 0, -n), today)
+}
+
+// NextDays matches dates from today till n days later inclusive
+func NextDays(n int) FMCondition {
+	today := truncateToDate(now())
+	return Between(today, today.AddDate(0, 0, n))
+}
+
+// IsEmpty matches empty fields
+func IsEmpty() FMCondition {
+	return FMCondition{Op: Empty}
+}
+
+// NotEmpty matches fields with any value
+func NotEmpty() FMCondition {
+	return FMCondition{Op: NonEmpty}
+}
+
+// Duplicates matches values occurring in more than one record
+func Duplicates() FMCondition {
+	return FMCondition{Op: Duplicated}
+}
+
+// Today matches today's date
+func Today() FMCondition {
+	return FMCondition{Op: IsToday}
+}
+
+// Invalid matches values of a wrong type, such as text in a number field
+func Invalid() FMCondition {
+	return FMCondition{Op: InvalidValue}
+}
+
+// Word matches fields containing the whole word w
+func Word(w string) FMCondition {
+	return FMCondition{Op: WholeWord, Value: w}
+}
+
+func newCondition(op FMFieldOp, v interface{}) FMCondition {
+	value, err := formatBound(v)
+	return FMCondition{Op: op, Value: value, err: err}
+}
+
+// formatBound formats the operand of the condition, only strings,
+// numbers, booleans, time.Time and pointers to them are supported
+func formatBound(v interface{}) (string, error) {
+	str, err := formatFMValue(v)
+	if err != nil {
+		return "", fmt.Errorf("invalid find value: %w", err)
+	}
+	return str, nil
+}
+
+func truncateToDate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
 // FMLogicalOp is a type for logical operators
 type FMLogicalOp string
 
@@ -144,6 +355,7 @@ type FMQuery struct {
 	QueryFields         []FMQueryFieldGroup
 	SortFields          []FMSortField
 	RecordID            int // default should be -1
+	ModID               int // sent with Edit when not 0
 	PreSortScript       string
 	PreFindScript       string
 	PostFindScript      string
@@ -155,6 +367,9 @@ type FMQuery struct {
 	MaxRecords          int // default should be -1
 	SkipRecords         int // default should be 0
 	Query               map[string]string
+
+	// buildErr is the first error that occurred in a builder method
+	buildErr error
 }
 
 // NewFMQuery creates new FMQuery object
@@ -176,6 +391,13 @@ func (q *FMQuery) WithRecordID(id int) *FMQuery {
 	return q
 }
 
+// WithModID sets modification id the record must have for Edit to succeed,
+// otherwise FileMaker returns an error matching ErrRecordModified
+func (q *FMQuery) WithModID(id int) *FMQuery {
+	q.ModID = id
+	return q
+}
+
 // WithFieldGroups sets groups of fields for find request
 func (q *FMQuery) WithFieldGroups(fieldGroups ...FMQueryFieldGroup) *FMQuery {
 	q.QueryFields = append(q.QueryFields, fieldGroups...)
@@ -219,6 +441,67 @@ func (q *FMQuery) WithPostFindScript(script, param string) *FMQuery {
 	return q
 }
 
+// WithPreSortScriptJSON sets PreSortScript and its param encoded as JSON
+func (q *FMQuery) WithPreSortScriptJSON(script string, param interface{}) *FMQuery {
+	q.PreSortScript = script
+	q.PreSortScriptParam = q.scriptJSON(param)
+	return q
+}
+
+// WithPreFindScriptJSON sets PreFindScript and its param encoded as JSON
+func (q *FMQuery) WithPreFindScriptJSON(script string, param interface{}) *FMQuery {
+	q.PreFindScript = script
+	q.PreFindScriptParam = q.scriptJSON(param)
+	return q
+}
+
+// WithPostFindScriptJSON sets PostFindScript and its param encoded as JSON
+func (q *FMQuery) WithPostFindScriptJSON(script string, param interface{}) *FMQuery {
+	q.PostFindScript = script
+	q.PostFindScriptParam = q.scriptJSON(param)
+	return q
+}
+
+// WithPreSortScriptList sets PreSortScript and its params encoded
+// as a return-delimited list, see ScriptParamList
+func (q *FMQuery) WithPreSortScriptList(script string, params ...interface{}) *FMQuery {
+	q.PreSortScript = script
+	q.PreSortScriptParam = q.scriptList(params)
+	return q
+}
+
+// WithPreFindScriptList sets PreFindScript and its params encoded
+// as a return-delimited list, see ScriptParamList
+func (q *FMQuery) WithPreFindScriptList(script string, params ...interface{}) *FMQuery {
+	q.PreFindScript = script
+	q.PreFindScriptParam = q.scriptList(params)
+	return q
+}
+
+// WithPostFindScriptList sets PostFindScript and its params encoded
+// as a return-delimited list, see ScriptParamList
+func (q *FMQuery) WithPostFindScriptList(script string, params ...interface{}) *FMQuery {
+	q.PostFindScript = script
+	q.PostFindScriptParam = q.scriptList(params)
+	return q
+}
+
+func (q *FMQuery) scriptJSON(param interface{}) string {
+	str, err := EncodeScriptJSON(param)
+	if err != nil {
+		q.setErr(err)
+	}
+	return str
+}
+
+func (q *FMQuery) scriptList(params []interface{}) string {
+	str, err := EncodeScriptList(params...)
+	if err != nil {
+		q.setErr(err)
+	}
+	return str
+}
+
 // WithResponseLayout sets layout name you want to fetch records from
 func (q *FMQuery) WithResponseLayout(lay string) *FMQuery {
 	q.ResponseLayout = lay
@@ -243,6 +526,44 @@ func (q *FMQuery) Skip(n int) *FMQuery {
 	return q
 }
 
+// WithParam sets an extra parameter sent with the query as it is,
+// e.g. an option the builder has no method for. Parameters the query
+// sends itself, such as -db or the action, fail the validation
+func (q *FMQuery) WithParam(key, value string) *FMQuery {
+	if q.Query == nil {
+		q.Query = map[string]string{}
+	}
+	q.Query[key] = value
+	return q
+}
+
+// Err returns the first error that occurred in the builder methods,
+// for example when a script param could not be encoded.
+// Query refuses to send a query with such an error
+func (q *FMQuery) Err() error {
+	return q.buildErr
+}
+
+func (q *FMQuery) setErr(err error) {
+	if q.buildErr == nil {
+		q.buildErr = err
+	}
+}
+
+// isReadOnly reports whether the query has no side effects,
+// so its result can be cached or shared between callers
+func (q *FMQuery) isReadOnly() bool {
+	if q.PreSortScript != "" || q.PreFindScript != "" || q.PostFindScript != "" {
+		return false
+	}
+	for key := range q.Query {
+		if strings.HasPrefix(key, "-script") {
+			return false
+		}
+	}
+	return q.Action == Find || q.Action == FindAll
+}
+
 func withAmp(s string) string {
 	if s == "" {
 		return ""
@@ -269,7 +590,7 @@ func (q *FMQuery) sortFieldsString() string {
 	colNum := 1
 	for _, f := range q.SortFields {
 		i := strconv.Itoa(colNum)
-		str := "-sortfield." + i + "=" + url.QueryEscape(f.Name) + "&-sortorder." + i + "=" + f.Order.String()
+		str := "-sortfield." + i + "=" + url.QueryEscape(f.Name) + "&-sortorder." + i + "=" + f.sortOrderString()
 		colNum++
 		strArray = append(strArray, str)
 	}
@@ -285,6 +606,21 @@ func (q *FMQuery) responseFieldsString() string {
 	return strings.Join(strArray, "&")
 }
 
+// paramsString is the extra parameters of the Query map sorted by name
+func (q *FMQuery) paramsString() string {
+	keys := make([]string, 0, len(q.Query))
+	for key := range q.Query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var strArray []string
+	for _, key := range keys {
+		strArray = append(strArray, url.QueryEscape(key)+"="+url.QueryEscape(q.Query[key]))
+	}
+	return strings.Join(strArray, "&")
+}
+
 func (q *FMQuery) scriptsString() string {
 	var preSort string
 	if q.PreSortScript != "" {
@@ -340,6 +676,13 @@ func (q *FMQuery) recordIDString() string {
 	return ""
 }
 
+func (q *FMQuery) modIDString() string {
+	if q.ModID != 0 {
+		return "-modid=" + strconv.Itoa(q.ModID)
+	}
+	return ""
+}
+
 func (q *FMQuery) responseLayoutString() string {
 	if q.ResponseLayout == "" {
 		return ""
@@ -409,7 +752,7 @@ func (q *FMQuery) compoundFieldsString() string {
 
 // QueryString creates query string based on FMQuery
 func (q *FMQuery) QueryString() string {
-	var startString = q.dbLayString() + withAmp(q.responseLayoutString()) + withAmp(q.scriptsString()) + withAmp(q.scriptParamsString())
+	var startString = q.dbLayString() + withAmp(q.responseLayoutString()) + withAmp(q.scriptsString()) + withAmp(q.scriptParamsString()) + withAmp(q.responseFieldsString()) + withAmp(q.paramsString())
 	switch q.Action {
 	case Delete, Duplicate:
 		return startString +
@@ -418,6 +761,7 @@ func (q *FMQuery) QueryString() string {
 	case Edit:
 		return startString +
 			withAmp(q.recordIDString()) +
+			withAmp(q.modIDString()) +
 			withAmp(q.simpleFieldsString()) +
 			q.Action.String()
 	case New:
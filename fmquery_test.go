@@ -3,6 +3,7 @@ package gofmcon
 import (
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestFieldsCount(t *testing.T) {
@@ -17,3 +18,92 @@ func TestFieldsCount(t *testing.T) {
 	q := FMQuery{QueryFields: a}
 	assert.Equal(t, 15, q.fieldsCount(), "FMQuery fieldsCount is not correct")
 }
+
+func TestValueWithOpEscapesFindSymbols(t *testing.T) {
+	ops := map[FMFieldOp][2]string{
+		Equal:            {"==", ""},
+		Contains:         {"==*", "*"},
+		BeginsWith:       {"==", "*"},
+		EndsWith:         {"==*", ""},
+		GreaterThan:      {">", ""},
+		GreaterThanEqual: {">=", ""},
+		LessThan:         {"<", ""},
+		LessThanEqual:    {"<=", ""},
+		"":               {"", ""},
+	}
+	escaped := `O'Neil\*\@\#\!\=\=1\.\.\.2\/\/\?\"\~\\`
+	for op, affixes := range ops {
+		f := FMQueryField{Name: "name", Value: `O'Neil*@#!==1...2//?"~\`, Op: op}
+		assert.Equal(t, affixes[0]+escaped+affixes[1], f.valueWithOp(), "op %q", op)
+	}
+
+	assert.Equal(t, ">1.5", (&FMQueryField{Value: "1.5", Op: GreaterThan}).valueWithOp())
+	assert.Equal(t, "==01/02/2020", (&FMQueryField{Value: "01/02/2020", Op: Equal}).valueWithOp())
+	assert.Equal(t, "1...5", (&FMQueryField{Value: "1...5", Raw: true}).valueWithOp())
+}
+
+func TestExtendedFindOperators(t *testing.T) {
+	date := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	timestamp := time.Date(2020, 3, 1, 14, 5, 0, 0, time.UTC)
+
+	cases := map[string]FMCondition{
+		"1...10.5":                         Between(1, 10.5),
+		"03/01/2020...03/01/2020 14:05:00": Between(date, timestamp),
+		"a\\*...z":                         Between("a*", "z"),
+		"=":                                IsEmpty(),
+		"*":                                NotEmpty(),
+		"!":                                Duplicates(),
+		"//":                               Today(),
+		"?":                                Invalid(),
+		"=open":                            Word("open"),
+		">=03/01/2020":                     Gte(date),
+		"<100":                             Lt(100),
+		"==true\\!":                        Eq("true!"),
+	}
+	for expected, cond := range cases {
+		f := cond.For("field")
+		assert.Equal(t, "field", f.Name)
+		assert.Equal(t, expected, f.valueWithOp())
+	}
+
+	now = func() time.Time { return time.Date(2020, 3, 1, 23, 59, 59, 0, time.Local) }
+	defer func() { now = time.Now }()
+	last, next := LastDays(7).For("created"), NextDays(7).For("created")
+	assert.Equal(t, "02/23/2020...03/01/2020", last.valueWithOp())
+	assert.Equal(t, "03/01/2020...03/08/2020", next.valueWithOp())
+}
+
+func TestUnsupportedFindValue(t *testing.T) {
+	type point struct{ X, Y int }
+	n := 5
+
+	assert.NoError(t, Eq(&n).Err())
+	f := Eq(&n).For("n")
+	assert.Equal(t, "==5", f.valueWithOp())
+	assert.Error(t, Eq(point{1, 2}).Err())
+	assert.Error(t, Between(1, []int{2}).Err())
+
+	q := NewFMQuery("db", "layout", Find).WithFields(Gt(point{1, 2}).For("total"), Eq("x").For("name"))
+	err := q.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "field total: invalid find value")
+	}
+	assert.Error(t, NewFMQuery("db", "layout", Find).WithExpr(FieldExpr("total", Lt(struct{}{}))).Validate())
+}
+
+func TestCustomSortOrder(t *testing.T) {
+	q := NewFMQuery("db", "layout", FindAll).WithSortFields(
+		FMSortField{Name: "name", Order: Ascending},
+		CustomSortField("status", "Status List"),
+	)
+	assert.NoError(t, q.Validate())
+	assert.Equal(t, "-sortfield.1=name&-sortorder.1=ascend&-sortfield.2=status&-sortorder.2=Status+List", q.sortFieldsString())
+
+	assert.Error(t, NewFMQuery("db", "layout", FindAll).WithSortFields(FMSortField{Name: "status", Order: Custom}).Validate())
+
+	tooMany := NewFMQuery("db", "layout", FindAll)
+	for i := 0; i < 10; i++ {
+		tooMany.WithSortFields(FMSortField{Name: "f", Order: Descending})
+	}
+	assert.EqualError(t, tooMany.Validate(), "invalid query: 10 sort fields given, FileMaker accepts at most 9")
+}
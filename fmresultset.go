@@ -32,6 +32,9 @@ func (rs *FMResultset) prepareRecords() {
 	if rs.MetaData != nil {
 		fd = rs.MetaData.getAllFieldDefinitions()
 	}
+	if rs.Resultset == nil {
+		return
+	}
 	for _, r := range rs.Resultset.Records {
 		r.makeFieldsMap(false, fd)
 	}
@@ -82,6 +85,33 @@ type RelatedSetDefinition struct {
 	FieldDefinitions []*FieldDefinition `xml:"field-definition"`
 }
 
+// RelatedSetDefinitions returns a definition for every related set on the layout.
+// Fields of all related sets are decoded into RelatedSetDefinition, which keeps
+// the table of the last one, so the fields are grouped by their table occurrence
+// the names of related fields start with
+func (md MetaData) RelatedSetDefinitions() []*RelatedSetDefinition {
+	if md.RelatedSetDefinition == nil {
+		return nil
+	}
+
+	var sets []*RelatedSetDefinition
+	byTable := map[string]*RelatedSetDefinition{}
+	for _, def := range md.RelatedSetDefinition.FieldDefinitions {
+		table := md.RelatedSetDefinition.Table
+		if i := strings.Index(def.Name, "::"); i >= 0 {
+			table = def.Name[:i]
+		}
+		set, ok := byTable[table]
+		if !ok {
+			set = &RelatedSetDefinition{Table: table}
+			byTable[table] = set
+			sets = append(sets, set)
+		}
+		set.FieldDefinitions = append(set.FieldDefinitions, def)
+	}
+	return sets
+}
+
 type fieldDefinition struct {
 	Name          string `xml:"name,attr"`
 	AutoEnter     string `xml:"auto-enter,attr"`
@@ -113,6 +143,18 @@ const (
 	TypeContainer FieldType = "container"
 )
 
+// FieldKind tells whether the field stores data or is calculated
+type FieldKind string
+
+const (
+	// KindNormal is a field storing data
+	KindNormal FieldKind = "normal"
+	// KindCalculation is a calculation field
+	KindCalculation FieldKind = "calculation"
+	// KindSummary is a summary field
+	KindSummary FieldKind = "summary"
+)
+
 // FieldDefinition store information about a field in given layout
 type FieldDefinition struct {
 	Name          string `xml:"name,attr"`
@@ -124,6 +166,7 @@ type FieldDefinition struct {
 	NumericOnly   bool
 	TimeOfDay     bool
 	Type          FieldType
+	Kind          FieldKind
 }
 
 // FieldsDefinitions is type of []FieldDefinition
@@ -165,6 +208,7 @@ func (f *FieldDefinition) UnmarshalXML(d *xml.Decoder, start xml.StartElement) e
 	f.TimeOfDay = getBoolFromString(fd.TimeOfDay)
 	f.Name = fd.Name
 	f.Type = FieldType(fd.Result)
+	f.Kind = FieldKind(fd.Type)
 	mr, _ := strconv.Atoi(fd.MaxRepeat)
 	f.MaxRepeat = mr
 
@@ -191,6 +235,20 @@ func (e *FMError) Error() string {
 	return fmt.Sprintf("filemaker_error: %s", FileMakerErrorCodes[e.Code])
 }
 
+// Is makes errors.Is match FMError with ErrRecordNotFound,
+// ErrRecordModified and ErrRecordInUse by its code
+func (e *FMError) Is(target error) bool {
+	switch target {
+	case ErrRecordNotFound:
+		return e.Code == 101 || e.Code == 401
+	case ErrRecordModified:
+		return e.Code == 306
+	case ErrRecordInUse:
+		return e.Code == 301
+	}
+	return false
+}
+
 // Resultset is a set of records with meta information
 type Resultset struct {
 	Count   int       `xml:"count,attr" json:"count"`
@@ -201,6 +259,7 @@ type Resultset struct {
 // Record is FileMaker record
 type Record struct {
 	ID         int      `xml:"record-id,attr"`
+	ModID      int      `xml:"mod-id,attr"`
 	Fields     []*Field `xml:"field"`
 	fieldsMap  map[string]interface{}
 	RelatedSet []*RelatedSet `xml:"relatedset"`
@@ -300,7 +359,126 @@ func (r *Record) Field(name string) interface{} {
 	return r.fieldsMap[name]
 }
 
+// rawValue returns the first value of the field as it was sent by FileMaker server
+func (r *Record) rawValue(name string) (string, bool) {
+	for _, f := range r.Fields {
+		if f.Name == name {
+			if len(f.Data) == 0 {
+				return "", true
+			}
+			return f.Data[0], true
+		}
+	}
+	return "", false
+}
+
 // JSONFields return JSON representation of Record
 func (r *Record) JSONFields() ([]byte, error) {
 	return json.MarshalIndent(r.fieldsMap, "", "	")
 }
+
+// clone returns a deep copy of the FMResultset, so it can be handed
+// to several callers without sharing records
+func (rs FMResultset) clone() FMResultset {
+	if rs.Resultset != nil {
+		resultset := *rs.Resultset
+		resultset.Records = cloneRecords(rs.Resultset.Records)
+		rs.Resultset = &resultset
+	}
+	if rs.DataSource != nil {
+		dataSource := *rs.DataSource
+		rs.DataSource = &dataSource
+	}
+	if rs.MetaData != nil {
+		metaData := MetaData{FieldDefinitions: cloneFieldDefinitions(rs.MetaData.FieldDefinitions)}
+		if rs.MetaData.RelatedSetDefinition != nil {
+			metaData.RelatedSetDefinition = &RelatedSetDefinition{
+				Table:            rs.MetaData.RelatedSetDefinition.Table,
+				FieldDefinitions: cloneFieldDefinitions(rs.MetaData.RelatedSetDefinition.FieldDefinitions),
+			}
+		}
+		rs.MetaData = &metaData
+	}
+	return rs
+}
+
+func cloneFieldDefinitions(fds []*FieldDefinition) []*FieldDefinition {
+	if fds == nil {
+		return nil
+	}
+	cloned := make([]*FieldDefinition, len(fds))
+	for i, fd := range fds {
+		def := *fd
+		cloned[i] = &def
+	}
+	return cloned
+}
+
+func cloneRecords(records []*Record) []*Record {
+	if records == nil {
+		return nil
+	}
+	cloned := make([]*Record, len(records))
+	for i, r := range records {
+		cloned[i] = r.clone()
+	}
+	return cloned
+}
+
+func (r *Record) clone() *Record {
+	nr := &Record{ID: r.ID, ModID: r.ModID}
+
+	if r.Fields != nil {
+		nr.Fields = make([]*Field, len(r.Fields))
+		for i, f := range r.Fields {
+			nr.Fields[i] = &Field{Name: f.Name, Type: f.Type, Data: append([]string(nil), f.Data...)}
+		}
+	}
+
+	if r.RelatedSet != nil {
+		nr.RelatedSet = make([]*RelatedSet, len(r.RelatedSet))
+		for i, rs := range r.RelatedSet {
+			nr.RelatedSet[i] = &RelatedSet{Count: rs.Count, Table: rs.Table, Records: cloneRecords(rs.Records)}
+		}
+	}
+
+	if r.fieldsMap != nil {
+		nr.fieldsMap = make(map[string]interface{}, len(r.fieldsMap))
+		for k, v := range r.fieldsMap {
+			nr.fieldsMap[k] = cloneFieldValue(v)
+		}
+		// related records must share their fields map with the parent
+		// the same way makeFieldsMap does it
+		for _, rs := range nr.RelatedSet {
+			if _, ok := r.fieldsMap[rs.Table]; !ok {
+				continue
+			}
+			var relatedRecordsFieldMaps []interface{}
+			for _, rr := range rs.Records {
+				relatedRecordsFieldMaps = append(relatedRecordsFieldMaps, rr.fieldsMap)
+			}
+			nr.fieldsMap[rs.Table] = relatedRecordsFieldMaps
+		}
+	}
+
+	return nr
+}
+
+func cloneFieldValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case []interface{}:
+		cloned := make([]interface{}, len(val))
+		for i, elem := range val {
+			cloned[i] = cloneFieldValue(elem)
+		}
+		return cloned
+	case map[string]interface{}:
+		cloned := make(map[string]interface{}, len(val))
+		for k, elem := range val {
+			cloned[k] = cloneFieldValue(elem)
+		}
+		return cloned
+	default:
+		return v
+	}
+}
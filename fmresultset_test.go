@@ -19,3 +19,27 @@ func TestFMResultsetXMLUnmarshal(t *testing.T) {
 	err = xml.Unmarshal(b, fmResultSet)
 	assert.NoError(t, err)
 }
+
+func TestRelatedSetDefinitions(t *testing.T) {
+	var md MetaData
+	err := xml.Unmarshal([]byte(`<metadata>
+<field-definition name="name" result="text"/>
+<relatedset-definition table="orders">
+<field-definition name="orders::total" result="number"/>
+<field-definition name="orders::item" result="text"/>
+</relatedset-definition>
+<relatedset-definition table="payments">
+<field-definition name="payments::amount" result="number"/>
+</relatedset-definition>
+</metadata>`), &md)
+	assert.NoError(t, err)
+
+	sets := md.RelatedSetDefinitions()
+	if assert.Len(t, sets, 2) {
+		assert.Equal(t, "orders", sets[0].Table)
+		assert.Len(t, sets[0].FieldDefinitions, 2)
+		assert.Equal(t, "payments", sets[1].Table)
+		assert.Equal(t, "payments::amount", sets[1].FieldDefinitions[0].Name)
+	}
+	assert.Nil(t, MetaData{}.RelatedSetDefinitions())
+}
@@ -3,8 +3,16 @@ module github.com/amanbolat/gofmcon
 go 1.20
 
 require (
+	github.com/mattn/go-sqlite3 v1.14.22
+	github.com/peterh/liner v1.2.1
 	github.com/pkg/errors v0.8.1
 	github.com/sirupsen/logrus v1.6.0
 	github.com/stretchr/testify v1.5.1
-	gopkg.in/yaml.v2 v2.2.8 // indirect
+	gopkg.in/yaml.v2 v2.2.8
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/mattn/go-runewidth v0.0.3 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 )
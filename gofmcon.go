@@ -2,6 +2,8 @@ package gofmcon
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -15,6 +17,10 @@ const (
 	fmiPath = "fmi/xml/fmresultset.xml"
 	// FMDBNames adds –dbnames (Database names) query command
 	FMDBNames = "-dbnames"
+	// FMLayoutNames adds -layoutnames (Layout names) query command
+	FMLayoutNames = "-layoutnames"
+	// FMScriptNames adds -scriptnames (Script names) query command
+	FMScriptNames = "-scriptnames"
 )
 
 // FMConnector includes all the information about FM database to be able to connect to that
@@ -25,6 +31,18 @@ type FMConnector struct {
 	Password string
 	Client   *http.Client
 	Debug    bool
+	// Credentials, if set, is consulted for every request
+	// instead of Username and Password
+	Credentials CredentialsProvider
+	// CacheConfig, if set, enables caching of read-only queries
+	CacheConfig *CacheConfig
+	// Deduplicate collapses concurrent identical read-only queries
+	// into one request to FileMaker server
+	Deduplicate bool
+
+	flight    flightGroup
+	cacheGens cacheGenerations
+	schemas   schemaCache
 }
 
 // NewFMConnector creates new FMConnector object
@@ -46,29 +64,43 @@ func (fmc *FMConnector) SetDebug(v bool) {
 	fmc.Debug = v
 }
 
+// SetCredentialsProvider sets the provider used to authorize requests
+func (fmc *FMConnector) SetCredentialsProvider(p CredentialsProvider) {
+	fmc.Credentials = p
+}
+
+// SetDeduplicate enables or disables collapsing of concurrent identical
+// read-only queries. Every caller gets its own copy of the FMResultset.
+// Note that if the context of the first caller is canceled,
+// all callers waiting for the same request get the error
+func (fmc *FMConnector) SetDeduplicate(v bool) {
+	fmc.Deduplicate = v
+}
+
+// credentials resolves the account for a request. Credentials stored in
+// the context win over the provider, and the provider wins over
+// Username and Password
+func (fmc *FMConnector) credentials(ctx context.Context) (Credentials, error) {
+	if creds, ok := CredentialsFromContext(ctx); ok {
+		return creds, nil
+	}
+	if fmc.Credentials != nil {
+		return fmc.Credentials.Credentials(ctx)
+	}
+	return Credentials{Username: fmc.Username, Password: fmc.Password}, nil
+}
+
 // Ping sends a simple request querying all available databases
 // in order to check connection and credentials
 func (fmc *FMConnector) Ping(ctx context.Context) error {
-	var newURL = &url.URL{}
-	newURL.Scheme = "http"
-	newURL.Host = fmc.Host
-	newURL.Path = fmiPath
-	newURL.RawQuery = newURL.Query().Encode() + "&" + FMDBNames
-	request, err := http.NewRequestWithContext(ctx, "GET", newURL.String(), nil)
-	if err != nil {
-		return fmt.Errorf("gofmcon.Ping: error create request: %w", err)
-	}
-	request.SetBasicAuth(fmc.Username, fmc.Password)
-	request.Header.Set("User-Agent", "Golang FileMaker Connector")
-	client := &http.Client{}
-	res, err := client.Do(request)
+	creds, err := fmc.credentials(ctx)
 	if err != nil {
-		return err
+		return fmt.Errorf("gofmcon.Ping: error get credentials: %w", err)
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode != 200 {
-		return fmt.Errorf("gofmcon.Ping: FileMaker server unreachable, status code: %d", res.StatusCode)
+	_, err = fmc.get(ctx, fmc.pathURL(fmiPath)+"?"+FMDBNames, creds)
+	if err != nil {
+		return fmt.Errorf("gofmcon.Ping: FileMaker server unreachable: %w", err)
 	}
 
 	return nil
@@ -77,38 +109,44 @@ func (fmc *FMConnector) Ping(ctx context.Context) error {
 // Query fetches FMResultset from FileMaker server depending on FMQuery
 // given to it
 func (fmc *FMConnector) Query(ctx context.Context, q *FMQuery) (FMResultset, error) {
-	resultSet := FMResultset{}
-	queryURL := fmc.makeURL(q)
-
-	request, err := http.NewRequestWithContext(ctx, "GET", queryURL, nil)
-	if err != nil {
-		return resultSet, fmt.Errorf("gofmcon.Query: error create request: %w", err)
+	if err := fmc.validate(q); err != nil {
+		return FMResultset{}, fmt.Errorf("gofmcon.Query: %w", err)
 	}
-	request.Header.Set("User-Agent", "Golang FileMaker Connector")
-	request.SetBasicAuth(fmc.Username, fmc.Password)
 
-	if fmc.Client == nil {
-		fmc.Client = http.DefaultClient
+	creds, err := fmc.credentials(ctx)
+	if err != nil {
+		return FMResultset{}, fmt.Errorf("gofmcon.Query: error get credentials: %w", err)
 	}
 
-	res, err := fmc.Client.Do(request)
-	if err != nil {
-		return resultSet, fmt.Errorf("gofmcon.Query: error http request: %w", err)
+	cacheEnabled := fmc.CacheConfig != nil && fmc.CacheConfig.Cache != nil
 
+	if !q.isReadOnly() {
+		if cacheEnabled {
+			defer fmc.cacheGens.invalidate(fmc.CacheConfig.Cache, cacheTag(q))
+		}
+		return fmc.query(ctx, q, creds)
 	}
-	defer res.Body.Close()
 
-	b, err := io.ReadAll(res.Body)
-	if err != nil {
-		return resultSet, fmt.Errorf("gofmcon.Query: error read response body: %w", err)
+	if cacheEnabled {
+		return fmc.cachedQuery(ctx, q, creds)
 	}
 
-	if res.StatusCode == 401 {
-		return resultSet, errors.New("gofmcon.Query: unauthorized")
+	if fmc.Deduplicate {
+		rs, err := fmc.flight.do(fmc.requestKey(q, creds), func() (FMResultset, error) {
+			return fmc.query(ctx, q, creds)
+		})
+		return rs.clone(), err
 	}
 
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		return resultSet, fmt.Errorf("gofmcon.Query: unknown error with status code: %d, %s", res.StatusCode, string(b))
+	return fmc.query(ctx, q, creds)
+}
+
+func (fmc *FMConnector) query(ctx context.Context, q *FMQuery, creds Credentials) (FMResultset, error) {
+	resultSet := FMResultset{}
+
+	b, err := fmc.get(ctx, fmc.makeURL(q), creds)
+	if err != nil {
+		return resultSet, fmt.Errorf("gofmcon.Query: %w", err)
 	}
 
 	err = xml.Unmarshal(b, &resultSet)
@@ -117,21 +155,74 @@ func (fmc *FMConnector) Query(ctx context.Context, q *FMQuery) (FMResultset, err
 	}
 
 	if resultSet.HasError() {
-		return resultSet, errors.New(resultSet.FMError.String())
+		fmErr := resultSet.FMError
+		return resultSet, &fmErr
 	}
 
 	resultSet.prepareRecords()
+	fmc.learnSchema(q, &resultSet)
 
 	return resultSet, nil
 }
 
+// requestKey identifies the query together with the server and the account
+// it is made with, as the same query returns different records to
+// accounts with different privilege sets
+func (fmc *FMConnector) requestKey(q *FMQuery, creds Credentials) string {
+	pass := sha256.Sum256([]byte(creds.Password))
+	return fmc.Host + ":" + fmc.Port + "\n" +
+		creds.Username + "\n" +
+		hex.EncodeToString(pass[:]) + "\n" +
+		q.CanonicalQueryString()
+}
+
 func (fmc *FMConnector) makeURL(q *FMQuery) string {
+	return fmc.pathURL(fmiPath) + "?" + q.QueryString()
+}
+
+func (fmc *FMConnector) pathURL(path string) string {
 	var newURL = &url.URL{}
 	newURL.Scheme = "http"
 	newURL.Host = fmc.Host
 	if fmc.Port != "" {
 		newURL.Host += ":" + fmc.Port
 	}
-	newURL.Path = fmiPath
-	return newURL.String() + "?" + q.QueryString()
+	newURL.Path = path
+	return newURL.String()
+}
+
+// get sends GET request to FileMaker server and returns the response body
+func (fmc *FMConnector) get(ctx context.Context, requestURL string, creds Credentials) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error create request: %w", err)
+	}
+	request.Header.Set("User-Agent", "Golang FileMaker Connector")
+	request.SetBasicAuth(creds.Username, creds.Password)
+
+	client := fmc.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("error http request: %w", err)
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error read response body: %w", err)
+	}
+
+	if res.StatusCode == 401 {
+		return nil, errors.New("unauthorized")
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("unknown error with status code: %d, %s", res.StatusCode, string(b))
+	}
+
+	return b, nil
 }
@@ -0,0 +1,98 @@
+package gofmcon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const emptyResultsetXML = `<fmresultset><error code="0"/><resultset count="0" fetch-size="0"/></fmresultset>`
+
+// newTestConnector starts a fake FileMaker server served by h and
+// returns a connector pointing to it
+func newTestConnector(t *testing.T, h http.HandlerFunc) *FMConnector {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewFMConnector(u.Hostname(), u.Port(), "user", "pass")
+}
+
+func TestQueryDeduplicate(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		_, _ = w.Write([]byte(singleRecordXML))
+	})
+	conn.SetDeduplicate(true)
+
+	results := make([]FMResultset, 5)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rs, err := conn.Query(context.Background(), NewFMQuery("db", "people", FindAll))
+			assert.NoError(t, err)
+			results[i] = rs
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.EqualValues(t, 1, atomic.LoadInt32(&requests))
+	results[0].Resultset.Records[0].fieldsMap["name"] = "mutated"
+	for _, rs := range results[1:] {
+		assert.Equal(t, "John", rs.Resultset.Records[0].Field("name"))
+	}
+
+	_, err := conn.Query(context.Background(), NewFMQuery("db", "people", FindAll))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&requests), "sequential queries must not be deduplicated")
+}
+
+func TestPing(t *testing.T) {
+	var rawQuery, user string
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		rawQuery = r.URL.RawQuery
+		user, _, _ = r.BasicAuth()
+		_, _ = w.Write([]byte(`<fmresultset><error code="0"/></fmresultset>`))
+	})
+	var requests int32
+	transport := http.DefaultTransport
+	conn.Client = &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&requests, 1)
+		return transport.RoundTrip(r)
+	})}
+
+	// the port of the test server is not the default one
+	assert.NoError(t, conn.Ping(context.Background()))
+	assert.Equal(t, "-dbnames", rawQuery)
+	assert.Equal(t, "user", user)
+	assert.Equal(t, int32(1), requests)
+
+	conn = newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	assert.Error(t, conn.Ping(context.Background()))
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,487 @@
+package gofmcon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ImportFormat is the format of the input of Import
+type ImportFormat int
+
+const (
+	// ImportCSV is CSV with a header row of column names
+	ImportCSV ImportFormat = iota
+	// ImportNDJSON is a JSON object per line, arrays are written to repetitions
+	ImportNDJSON
+)
+
+// ImportOptions configures FMConnector.Import
+type ImportOptions struct {
+	Format ImportFormat
+	// Comma is the separator of CSV columns, ',' by default
+	Comma rune
+	// Mapping maps columns of the input to fields of the layout, columns which
+	// are not mapped are skipped. Without Mapping every column is imported
+	// to the field with the same name. Repetitions of a column named like
+	// phone(2) are imported to the same repetition of the mapped field
+	Mapping map[string]string
+	// KeyFields, if set, makes rows upserts, see FMConnector.Upsert: the record
+	// with the same values of the key fields is edited, a new record is created
+	// if there is none. A row matching more than one record fails with ErrDuplicateKey
+	KeyFields []string
+	// Concurrency is the maximum number of rows imported at the same time
+	Concurrency int
+	// DryRun reads and converts all rows and looks up the keys of upserts,
+	// but doesn't create or edit records
+	DryRun bool
+	// Progress, if set, is called after every imported row
+	Progress func(done, total int)
+}
+
+// ImportRowError is the error of a single row of the input
+type ImportRowError struct {
+	// Row is the number of the row in the input starting from 1,
+	// not counting the CSV header
+	Row int
+	Err error
+}
+
+func (e *ImportRowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+func (e *ImportRowError) Unwrap() error {
+	return e.Err
+}
+
+// ImportReport is the outcome of Import. In dry run Created and Updated
+// are the numbers of records which would be created and updated
+type ImportReport struct {
+	Rows    int
+	Created int
+	Updated int
+	Errors  []*ImportRowError
+}
+
+// importCell is a value of a column of the input, rep is 0
+// unless the column names a repetition
+type importCell struct {
+	column string
+	rep    int
+	value  string
+}
+
+// importRow is a row of the input converted to the fields of the layout
+type importRow struct {
+	num    int
+	values map[string]string
+	err    error
+}
+
+var importColumnName = regexp.MustCompile(`^(.+)\((\d+)\)$`)
+
+// Import reads rows from r and creates a record of the layout for every row,
+// or upserts it if ImportOptions.KeyFields are set. Columns are checked
+// against the field definitions of the layout before any record is written
+// and values are converted to the formats of the field types, e.g. dates
+// in ISO 8601 are written as DateFormat. Timestamps with a zone offset are
+// converted to UTC first. A row which can't be converted or
+// written is reported in ImportReport.Errors and doesn't stop the import.
+// The returned error is not nil if the input can't be read or doesn't match
+// the layout, or if ctx is done
+func (fmc *FMConnector) Import(ctx context.Context, database, layout string, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	fds, err := fmc.LayoutFields(ctx, database, layout)
+	if err != nil {
+		return nil, fmt.Errorf("gofmcon.Import: %w", err)
+	}
+
+	var cells [][]importCell
+	var readErrs []*ImportRowError
+	switch opts.Format {
+	case ImportCSV:
+		cells, err = readImportCSV(r, opts.Comma)
+	case ImportNDJSON:
+		cells, readErrs, err = readImportNDJSON(r)
+	default:
+		err = fmt.Errorf("unknown format %d", opts.Format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gofmcon.Import: %w", err)
+	}
+
+	rows, keyFields, err := convertImportRows(cells, fds, opts)
+	if err != nil {
+		return nil, fmt.Errorf("gofmcon.Import: %w", err)
+	}
+
+	report := &ImportReport{Rows: len(rows)}
+	var mu sync.Mutex
+	fail := func(row *importRow, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		report.Errors = append(report.Errors, &ImportRowError{Row: row.num, Err: err})
+	}
+	for _, e := range readErrs {
+		report.Errors = append(report.Errors, e)
+	}
+
+	err = runBounded(ctx, len(rows), BatchOptions{Concurrency: opts.Concurrency, Progress: opts.Progress}, func(ctx context.Context, i int) error {
+		row := &rows[i]
+		if errors.Is(row.err, errSkippedRow) {
+			return nil
+		}
+		if row.err != nil {
+			fail(row, row.err)
+			return nil
+		}
+
+		created, err := fmc.importRow(ctx, database, layout, keyFields, row, opts.DryRun)
+		if err != nil {
+			fail(row, err)
+			return nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if created {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+		return nil
+	})
+
+	sort.Slice(report.Errors, func(i, j int) bool {
+		return report.Errors[i].Row < report.Errors[j].Row
+	})
+	if err != nil {
+		return report, fmt.Errorf("gofmcon.Import: %w", err)
+	}
+	return report, nil
+}
+
+// importRow writes the row and reports whether a record was created
+func (fmc *FMConnector) importRow(ctx context.Context, database, layout string, keyFields []string, row *importRow, dryRun bool) (bool, error) {
+	if len(keyFields) > 0 && dryRun {
+		key, err := upsertKey(keyFields, row.values)
+		if err != nil {
+			return false, err
+		}
+		found, err := fmc.findByKey(ctx, database, layout, key)
+		return found == nil, err
+	}
+	if len(keyFields) > 0 {
+		res, err := fmc.Upsert(ctx, database, layout, keyFields, row.values)
+		if err != nil {
+			return false, err
+		}
+		return res.Created, nil
+	}
+	if dryRun {
+		return true, nil
+	}
+
+	names := make([]string, 0, len(row.values))
+	for name := range row.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	q := NewFMQuery(database, layout, New)
+	for _, name := range names {
+		q.WithFields(FMQueryField{Name: name, Value: row.values[name]})
+	}
+	_, err := fmc.Query(ctx, q)
+	return true, err
+}
+
+func readImportCSV(r io.Reader, comma rune) ([][]importCell, error) {
+	cr := csv.NewReader(r)
+	if comma != 0 {
+		cr.Comma = comma
+	}
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error read csv header: %w", err)
+	}
+	columns := make([]importCell, len(header))
+	for i, name := range header {
+		columns[i] = splitImportColumn(strings.TrimSpace(name))
+	}
+
+	var rows [][]importCell
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error read csv: %w", err)
+		}
+
+		row := make([]importCell, len(columns))
+		for i, c := range columns {
+			row[i] = importCell{column: c.column, rep: c.rep, value: record[i]}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// readImportNDJSON reads the lines, lines which are not JSON objects are
+// returned as row errors with nil cells, so they keep their numbers
+func readImportNDJSON(r io.Reader) ([][]importCell, []*ImportRowError, error) {
+	var rows [][]importCell
+	var rowErrs []*ImportRowError
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 16*1024*1024)
+	for s.Scan() {
+		line := bytes.TrimSpace(s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		rows = append(rows, nil)
+
+		row, err := decodeImportObject(line)
+		if err != nil {
+			rowErrs = append(rowErrs, &ImportRowError{Row: len(rows), Err: err})
+			continue
+		}
+		rows[len(rows)-1] = row
+	}
+	if err := s.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error read ndjson: %w", err)
+	}
+	return rows, rowErrs, nil
+}
+
+func decodeImportObject(line []byte) ([]importCell, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("error unmarshal json: %w", err)
+	}
+
+	var names []string
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	row := []importCell{}
+	for _, name := range names {
+		c := splitImportColumn(name)
+		values, ok := obj[name].([]interface{})
+		if !ok {
+			v, err := importJSONValue(obj[name])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			row = append(row, importCell{column: c.column, rep: c.rep, value: v})
+			continue
+		}
+		for i, value := range values {
+			v, err := importJSONValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			row = append(row, importCell{column: c.column, rep: i + 1, value: v})
+		}
+	}
+	return row, nil
+}
+
+func importJSONValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
+
+func splitImportColumn(name string) importCell {
+	if m := importColumnName.FindStringSubmatch(name); m != nil {
+		rep, _ := strconv.Atoi(m[2])
+		return importCell{column: m[1], rep: rep}
+	}
+	return importCell{column: name}
+}
+
+// convertImportRows maps the columns to the fields of the layout and converts
+// the values, it also returns the key fields named as on the layout. Columns
+// missing on the layout fail the whole import, values which can't be
+// converted fail their rows
+func convertImportRows(cells [][]importCell, fds FieldsDefinitions, opts ImportOptions) ([]importRow, []string, error) {
+	var missing []string
+	seen := map[string]bool{}
+	target := func(column string) (FieldDefinition, bool) {
+		name := column
+		if opts.Mapping != nil {
+			var ok bool
+			if name, ok = opts.Mapping[column]; !ok {
+				return FieldDefinition{}, false
+			}
+		}
+		fd, ok := fds.definition(name)
+		if !ok && !seen[name] {
+			seen[name] = true
+			missing = append(missing, name)
+		}
+		return fd, ok
+	}
+
+	var keyFields []string
+	for _, k := range opts.KeyFields {
+		fd, ok := fds.definition(k)
+		if !ok {
+			return nil, nil, fmt.Errorf("key field %s is missing on the layout", k)
+		}
+		keyFields = append(keyFields, fd.Name)
+	}
+
+	rows := make([]importRow, len(cells))
+	for i, row := range cells {
+		rows[i].num = i + 1
+		rows[i].values = map[string]string{}
+		for _, c := range row {
+			fd, ok := target(c.column)
+			if !ok {
+				continue
+			}
+			if c.rep > fd.MaxRepeat && fd.MaxRepeat > 0 {
+				rows[i].err = fmt.Errorf("field %s has %d repetitions, got repetition %d", fd.Name, fd.MaxRepeat, c.rep)
+				continue
+			}
+
+			value, err := convertImportValue(c.value, fd.Type)
+			if err != nil && rows[i].err == nil {
+				rows[i].err = fmt.Errorf("%s: %w", c.column, err)
+			}
+
+			name := fd.Name
+			if c.rep > 1 {
+				name = fmt.Sprintf("%s(%d)", fd.Name, c.rep)
+			}
+			rows[i].values[name] = value
+		}
+
+		if cells[i] == nil && rows[i].err == nil {
+			// the line was already reported by readImportNDJSON
+			rows[i].err = errSkippedRow
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, nil, fmt.Errorf("fields are missing on the layout: %s", strings.Join(missing, ", "))
+	}
+	return rows, keyFields, nil
+}
+
+// errSkippedRow marks rows already reported while reading the input
+var errSkippedRow = errors.New("skipped")
+
+// thousandsNumber is a number with commas separating the thousands
+var thousandsNumber = regexp.MustCompile(`^[-+]?\d{1,3}(,\d{3})+(\.\d+)?$`)
+
+// decimalNumber is a number without exponent, grouping or other symbols
+var decimalNumber = regexp.MustCompile(`^([-+]?)(\d*)(?:\.(\d*))?$`)
+
+// importTimeFormats are the formats accepted for date, time and timestamp fields
+var importTimeFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	TimestampFormat,
+	DateFormat,
+	"15:04:05",
+	"15:04",
+}
+
+// normalizeNumber returns the decimal number without thousands separators,
+// a plus sign and insignificant zeros. The digits are kept as they are,
+// FileMaker numbers are more precise than float64
+func normalizeNumber(v string) (string, bool) {
+	// commas are only stripped as thousands separators, 1,5 is not 15
+	if thousandsNumber.MatchString(v) {
+		v = strings.ReplaceAll(v, ",", "")
+	}
+	m := decimalNumber.FindStringSubmatch(v)
+	if m == nil || m[2] == "" && m[3] == "" {
+		return "", false
+	}
+
+	sign, whole, fraction := m[1], strings.TrimLeft(m[2], "0"), strings.TrimRight(m[3], "0")
+	if whole == "" {
+		whole = "0"
+	}
+	if sign == "+" || whole == "0" && fraction == "" {
+		sign = ""
+	}
+	if fraction != "" {
+		return sign + whole + "." + fraction, true
+	}
+	return sign + whole, true
+}
+
+// convertImportValue converts the value to the format FileMaker expects for the field type
+func convertImportValue(v string, typ FieldType) (string, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "", nil
+	}
+
+	switch typ {
+	case TypeNumber:
+		n, ok := normalizeNumber(v)
+		if !ok {
+			return "", fmt.Errorf("invalid number %q", v)
+		}
+		return n, nil
+	case TypeDate, TypeTime, TypeTimestamp:
+		for _, layout := range importTimeFormats {
+			t, err := time.Parse(layout, v)
+			if err != nil {
+				continue
+			}
+			// values with an offset are written in UTC, the zone values
+			// read from FileMaker are parsed in
+			t = t.UTC()
+			switch typ {
+			case TypeDate:
+				return t.Format(DateFormat), nil
+			case TypeTime:
+				return t.Format(TimeFormat), nil
+			default:
+				return t.Format(TimestampFormat), nil
+			}
+		}
+		return "", fmt.Errorf("invalid %s %q", typ, v)
+	}
+	return v, nil
+}
@@ -0,0 +1,159 @@
+package gofmcon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// importFields are the fields of the layout the rows are imported to
+var importFields = FieldsDefinitions{
+	{Name: "code", Type: TypeText, MaxRepeat: 1},
+	{Name: "name", Type: TypeText, MaxRepeat: 1},
+	{Name: "phone", Type: TypeText, MaxRepeat: 2},
+	{Name: "total", Type: TypeNumber, MaxRepeat: 1},
+	{Name: "since", Type: TypeDate, MaxRepeat: 1},
+	{Name: "seen", Type: TypeTimestamp, MaxRepeat: 1},
+}
+
+func TestImportCSV(t *testing.T) {
+	fake, conn := newFakeFileMaker(t)
+	fake.fields = importFields
+	ctx := context.Background()
+
+	input := "code,Name,phone(2),total,since,seen\n" +
+		"a1,Jane,555,\"1,200.50\",2020-01-02,2020-01-02T15:04:05Z\n" +
+		"a2,John,,x,,\n" +
+		"a3,Bob,,,01/03/2020,2020-01-03 10:00:00\n"
+	var done int
+	report, err := conn.Import(ctx, "db", "customers", strings.NewReader(input), ImportOptions{
+		Concurrency: 2,
+		Progress:    func(d, total int) { done = d },
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, done)
+	assert.Equal(t, 3, report.Rows)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 0, report.Updated)
+	if assert.Len(t, report.Errors, 1) {
+		assert.Equal(t, 2, report.Errors[0].Row)
+		assert.Equal(t, `row 2: total: invalid number "x"`, report.Errors[0].Error())
+	}
+
+	if assert.Len(t, fake.records, 2) {
+		var jane *fakeRecord
+		for _, rec := range fake.records {
+			if fakeValue(rec, "code") == "a1" {
+				jane = rec
+			}
+		}
+		if assert.NotNil(t, jane) {
+			assert.Equal(t, map[string][]string{
+				"code":  {"a1"},
+				"name":  {"Jane"},
+				"phone": {"", "555"},
+				"total": {"1200.5"},
+				"since": {"01/02/2020"},
+				"seen":  {"01/02/2020 15:04:05"},
+			}, jane.fields)
+		}
+	}
+
+	_, err = conn.Import(ctx, "db", "customers", strings.NewReader("code,email\na1,x\n"), ImportOptions{})
+	assert.EqualError(t, err, "gofmcon.Import: fields are missing on the layout: email")
+	_, err = conn.Import(ctx, "db", "customers", strings.NewReader("code\na1\n"), ImportOptions{KeyFields: []string{"id"}})
+	assert.Error(t, err)
+	assert.Len(t, fake.records, 2)
+}
+
+func TestImportUpsert(t *testing.T) {
+	fake, conn := newFakeFileMaker(t)
+	fake.fields = importFields
+	fake.add(map[string]string{"code": "a1", "name": "Jane"})
+	fake.add(map[string]string{"code": "a2", "name": "John"})
+	fake.add(map[string]string{"code": "a2", "name": "Johnny"})
+	ctx := context.Background()
+
+	input := `{"id": "a1", "full name": "Jane Doe", "phones": ["1", "2"]}` + "\n" +
+		"\n" +
+		`{"id": "a2", "full name": "John Doe"}` + "\n" +
+		`{"id": "a3", "full name": "Bob", "amount": 7}` + "\n" +
+		`not json` + "\n" +
+		`{"full name": "Nobody"}` + "\n"
+	opts := ImportOptions{
+		Format:    ImportNDJSON,
+		Mapping:   map[string]string{"id": "code", "full name": "name", "phones": "phone", "amount": "total"},
+		KeyFields: []string{"code"},
+		DryRun:    true,
+	}
+
+	report, err := conn.Import(ctx, "db", "customers", strings.NewReader(input), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, report.Rows)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Updated)
+	if assert.Len(t, report.Errors, 3) {
+		assert.Equal(t, 2, report.Errors[0].Row)
+		assert.True(t, errors.Is(report.Errors[0], ErrDuplicateKey))
+		assert.Equal(t, 4, report.Errors[1].Row)
+		assert.Equal(t, 5, report.Errors[2].Row)
+	}
+	for _, q := range fake.queries {
+		assert.NotContains(t, []FMAction{New, Edit}, q.Action)
+	}
+	assert.Len(t, fake.records, 3)
+
+	opts.DryRun = false
+	report, err = conn.Import(ctx, "db", "customers", strings.NewReader(input), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Updated)
+	assert.Len(t, report.Errors, 3)
+
+	assert.Equal(t, map[string][]string{"code": {"a1"}, "name": {"Jane Doe"}, "phone": {"1", "2"}}, fake.get(1).fields)
+	assert.Equal(t, map[string][]string{"code": {"a3"}, "name": {"Bob"}, "total": {"7"}}, fake.get(4).fields)
+}
+
+func TestConvertImportValue(t *testing.T) {
+	tests := []struct {
+		value string
+		typ   FieldType
+		want  string
+	}{
+		{" text ", TypeText, "text"},
+		{"1,000.50", TypeNumber, "1000.5"},
+		{"-3", TypeNumber, "-3"},
+		{"-1,234,567", TypeNumber, "-1234567"},
+		{"1.5", TypeNumber, "1.5"},
+		{"12345678901234567890", TypeNumber, "12345678901234567890"},
+		{"+007.250", TypeNumber, "7.25"},
+		{".5", TypeNumber, "0.5"},
+		{"-0.0", TypeNumber, "0"},
+		{"2020-01-31", TypeDate, "01/31/2020"},
+		{"01/31/2020", TypeDate, "01/31/2020"},
+		{"2020-01-31T10:00:00+06:00", TypeDate, "01/31/2020"},
+		{"2020-01-31T03:00:00+06:00", TypeDate, "01/30/2020"},
+		{"2020-01-31T10:00:00+06:00", TypeTimestamp, "01/31/2020 04:00:00"},
+		{"2020-01-31T10:00:00Z", TypeTime, "10:00:00"},
+		{"10:20", TypeTime, "10:20:00"},
+		{"2020-01-31 10:20:30", TypeTimestamp, "01/31/2020 10:20:30"},
+		{"2020-01-31", TypeTimestamp, "01/31/2020 00:00:00"},
+		{"", TypeDate, ""},
+	}
+	for _, tt := range tests {
+		got, err := convertImportValue(tt.value, tt.typ)
+		assert.NoError(t, err, tt.value)
+		assert.Equal(t, tt.want, got, tt.value)
+	}
+
+	_, err := convertImportValue("yesterday", TypeDate)
+	assert.Error(t, err)
+	for _, v := range []string{"ten", "1,5", "1,0000", "12,34.5", ",100", "1e3", "0x10", ".", "-", "1.2.3"} {
+		_, err = convertImportValue(v, TypeNumber)
+		assert.EqualError(t, err, fmt.Sprintf("invalid number %q", v))
+	}
+}
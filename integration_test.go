@@ -1,6 +1,7 @@
 package gofmcon
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/stretchr/testify/assert"
 	"os"
@@ -28,13 +29,16 @@ type NestedTable struct {
 
 func Test1(t *testing.T) {
 	fmHost := os.Getenv("FM_HOST")
+	if fmHost == "" {
+		t.Skip("FM_HOST is not set")
+	}
 	fmPort := os.Getenv("FM_PORT")
 	fmUser := os.Getenv("FM_USER")
 	fmPass := os.Getenv("FM_PASS")
 	conn := NewFMConnector(fmHost, fmPort, fmUser, fmPass)
 	q := NewFMQuery("test", "table", FindAll)
 	q.WithResponseLayout("table")
-	res, err := conn.Query(q)
+	res, err := conn.Query(context.Background(), q)
 	assert.NoError(t, err)
 	assert.NotNil(t, res)
 
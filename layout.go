@@ -0,0 +1,103 @@
+package gofmcon
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+)
+
+const fmiLayoutPath = "fmi/xml/FMPXMLLAYOUT.xml"
+
+// FMLayout is a description of a layout in FMPXMLLAYOUT grammar,
+// which unlike fmresultset includes value lists of the fields
+type FMLayout struct {
+	ErrorCode  int         `xml:"ERRORCODE"`
+	Layout     LayoutInfo  `xml:"LAYOUT"`
+	ValueLists []ValueList `xml:"VALUELISTS>VALUELIST"`
+}
+
+// LayoutInfo is the layout with its fields
+type LayoutInfo struct {
+	Database string        `xml:"DATABASE,attr"`
+	Name     string        `xml:"NAME,attr"`
+	Fields   []LayoutField `xml:"FIELD"`
+}
+
+// LayoutField is a field placed on the layout
+type LayoutField struct {
+	Name  string           `xml:"NAME,attr"`
+	Style LayoutFieldStyle `xml:"STYLE"`
+}
+
+// LayoutFieldStyle is the control style of the field, e.g. EDITTEXT or POPUPMENU,
+// and the value list attached to it
+type LayoutFieldStyle struct {
+	Type      string `xml:"TYPE,attr"`
+	ValueList string `xml:"VALUELIST,attr"`
+}
+
+// ValueList is a value list used on the layout
+type ValueList struct {
+	Name   string          `xml:"NAME,attr"`
+	Values []ValueListItem `xml:"VALUE"`
+}
+
+// ValueListItem is a value of the value list with the text displayed for it
+type ValueListItem struct {
+	Display string `xml:"DISPLAY,attr"`
+	Value   string `xml:",chardata"`
+}
+
+// ValueList returns the value list with the name or nil
+func (l *FMLayout) ValueList(name string) *ValueList {
+	for i := range l.ValueLists {
+		if l.ValueLists[i].Name == name {
+			return &l.ValueLists[i]
+		}
+	}
+	return nil
+}
+
+// FieldValueList returns the name of the value list attached to the field
+func (l *FMLayout) FieldValueList(field string) string {
+	for _, f := range l.Layout.Fields {
+		if f.Name == field {
+			return f.Style.ValueList
+		}
+	}
+	return ""
+}
+
+// ParseLayout parses FMPXMLLAYOUT response, e.g. one saved to a file
+func ParseLayout(data []byte) (FMLayout, error) {
+	var l FMLayout
+	err := xml.Unmarshal(data, &l)
+	if err != nil {
+		return l, fmt.Errorf("gofmcon.ParseLayout: error unmarshal xml: %w", err)
+	}
+	if l.ErrorCode != 0 {
+		return l, &FMError{Code: l.ErrorCode}
+	}
+	return l, nil
+}
+
+// Layout fetches description of the layout with value lists of its fields
+func (fmc *FMConnector) Layout(ctx context.Context, database, layout string) (FMLayout, error) {
+	creds, err := fmc.credentials(ctx)
+	if err != nil {
+		return FMLayout{}, fmt.Errorf("gofmcon.Layout: error get credentials: %w", err)
+	}
+
+	query := "-db=" + url.QueryEscape(database) + "&-lay=" + url.QueryEscape(layout) + "&-view"
+	b, err := fmc.get(ctx, fmc.pathURL(fmiLayoutPath)+"?"+query, creds)
+	if err != nil {
+		return FMLayout{}, fmt.Errorf("gofmcon.Layout: %w", err)
+	}
+
+	l, err := ParseLayout(b)
+	if err != nil {
+		return l, fmt.Errorf("gofmcon.Layout: %w", err)
+	}
+	return l, nil
+}
@@ -0,0 +1,45 @@
+package gofmcon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const layoutXML = `<?xml version="1.0" encoding="UTF-8"?>
+<FMPXMLLAYOUT xmlns="http://www.filemaker.com/fmpxmllayout">
+<ERRORCODE>0</ERRORCODE>
+<PRODUCT BUILD="1/15/2015" NAME="FileMaker Web Publishing Engine" VERSION="13.0.9.905"/>
+<LAYOUT DATABASE="db" NAME="customers">
+<FIELD NAME="name"><STYLE TYPE="EDITTEXT" VALUELIST=""/></FIELD>
+<FIELD NAME="status"><STYLE TYPE="POPUPMENU" VALUELIST="Statuses"/></FIELD>
+</LAYOUT>
+<VALUELISTS>
+<VALUELIST NAME="Statuses"><VALUE DISPLAY="Open">open</VALUE><VALUE DISPLAY="Closed">closed</VALUE></VALUELIST>
+</VALUELISTS>
+</FMPXMLLAYOUT>`
+
+func TestLayout(t *testing.T) {
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/fmi/xml/FMPXMLLAYOUT.xml", r.URL.Path)
+		assert.Equal(t, "-db=db&-lay=customers&-view", r.URL.RawQuery)
+		_, _ = w.Write([]byte(layoutXML))
+	})
+
+	l, err := conn.Layout(context.Background(), "db", "customers")
+	assert.NoError(t, err)
+	assert.Equal(t, "customers", l.Layout.Name)
+	assert.Len(t, l.Layout.Fields, 2)
+	assert.Equal(t, "Statuses", l.FieldValueList("status"))
+	assert.Equal(t, "", l.FieldValueList("name"))
+	assert.Equal(t, []ValueListItem{{Display: "Open", Value: "open"}, {Display: "Closed", Value: "closed"}}, l.ValueList("Statuses").Values)
+	assert.Nil(t, l.ValueList("missing"))
+
+	_, err = ParseLayout([]byte(`<FMPXMLLAYOUT><ERRORCODE>105</ERRORCODE></FMPXMLLAYOUT>`))
+	var fmErr *FMError
+	assert.True(t, errors.As(err, &fmErr))
+	assert.Equal(t, 105, fmErr.Code)
+}
@@ -0,0 +1,75 @@
+package gofmcon
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+)
+
+// DatabaseNames returns names of the databases hosted on the server
+// which the account can access with XML Web Publishing
+func (fmc *FMConnector) DatabaseNames(ctx context.Context) ([]string, error) {
+	names, err := fmc.names(ctx, FMDBNames, "")
+	if err != nil {
+		return nil, fmt.Errorf("gofmcon.DatabaseNames: %w", err)
+	}
+	return names, nil
+}
+
+// LayoutNames returns names of the layouts of the database
+func (fmc *FMConnector) LayoutNames(ctx context.Context, database string) ([]string, error) {
+	names, err := fmc.names(ctx, FMLayoutNames, database)
+	if err != nil {
+		return nil, fmt.Errorf("gofmcon.LayoutNames: %w", err)
+	}
+	return names, nil
+}
+
+// ScriptNames returns names of the scripts of the database
+func (fmc *FMConnector) ScriptNames(ctx context.Context, database string) ([]string, error) {
+	names, err := fmc.names(ctx, FMScriptNames, database)
+	if err != nil {
+		return nil, fmt.Errorf("gofmcon.ScriptNames: %w", err)
+	}
+	return names, nil
+}
+
+// names sends one of the name commands, FileMaker returns every name
+// as a record with a single field, e.g. LAYOUT_NAME
+func (fmc *FMConnector) names(ctx context.Context, command, database string) ([]string, error) {
+	creds, err := fmc.credentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error get credentials: %w", err)
+	}
+
+	query := command
+	if database != "" {
+		query = "-db=" + url.QueryEscape(database) + "&" + command
+	}
+	b, err := fmc.get(ctx, fmc.pathURL(fmiPath)+"?"+query, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	var rs FMResultset
+	err = xml.Unmarshal(b, &rs)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshal xml: %w", err)
+	}
+	if rs.HasError() {
+		fmErr := rs.FMError
+		return nil, &fmErr
+	}
+
+	names := []string{}
+	if rs.Resultset == nil {
+		return names, nil
+	}
+	for _, rec := range rs.Resultset.Records {
+		if len(rec.Fields) > 0 && len(rec.Fields[0].Data) > 0 {
+			names = append(names, rec.Fields[0].Data[0])
+		}
+	}
+	return names, nil
+}
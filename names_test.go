@@ -0,0 +1,60 @@
+package gofmcon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func namesXML(field string, names ...string) string {
+	s := `<fmresultset><error code="0"/><metadata><field-definition name="` + field + `" result="text" max-repeat="1"/></metadata>` +
+		`<resultset count="1" fetch-size="1">`
+	for _, name := range names {
+		s += `<record record-id="0" mod-id="0"><field name="` + field + `"><data>` + name + `</data></field></record>`
+	}
+	return s + `</resultset></fmresultset>`
+}
+
+func TestNames(t *testing.T) {
+	var queries []string
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		switch {
+		case r.URL.Query().Has("-dbnames"):
+			_, _ = w.Write([]byte(namesXML("DATABASE_NAME", "sales", "stock")))
+		case r.URL.Query().Has("-layoutnames"):
+			_, _ = w.Write([]byte(namesXML("LAYOUT_NAME", "customers", "orders")))
+		case r.URL.Query().Get("-db") == "empty":
+			_, _ = w.Write([]byte(`<fmresultset><error code="0"/><resultset count="0" fetch-size="0"></resultset></fmresultset>`))
+		default:
+			_, _ = w.Write([]byte(`<fmresultset><error code="802"/></fmresultset>`))
+		}
+	})
+	ctx := context.Background()
+
+	dbs, err := conn.DatabaseNames(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sales", "stock"}, dbs)
+
+	layouts, err := conn.LayoutNames(ctx, "sales & co")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"customers", "orders"}, layouts)
+
+	scripts, err := conn.ScriptNames(ctx, "empty")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, scripts)
+
+	_, err = conn.ScriptNames(ctx, "missing")
+	var fmErr *FMError
+	assert.True(t, errors.As(err, &fmErr))
+
+	assert.Equal(t, []string{
+		"-dbnames",
+		"-db=sales+%26+co&-layoutnames",
+		"-db=empty&-scriptnames",
+		"-db=missing&-scriptnames",
+	}, queries)
+}
@@ -0,0 +1,304 @@
+package gofmcon
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fmFindByID is the action QueryString uses for finds
+// by record id and finds without fields
+const fmFindByID = "-find"
+
+var fmActions = map[string]FMAction{
+	Find.String():      Find,
+	fmFindByID:         Find,
+	FindAll.String():   FindAll,
+	FindAny.String():   FindAny,
+	New.String():       New,
+	Edit.String():      Edit,
+	Delete.String():    Delete,
+	Duplicate.String(): Duplicate,
+}
+
+// ParseFMQuery reconstructs FMQuery from a query string made by QueryString,
+// or from a full URL of a FileMaker XML request. Parameters FMQuery has no
+// field for are kept in the Query map. Find values are turned back into
+// operators where possible, other values are kept as Raw fields, so
+// QueryString of the parsed query sends the same find
+func ParseFMQuery(rawQuery string) (*FMQuery, error) {
+	if idx := strings.Index(rawQuery, "?"); idx >= 0 {
+		rawQuery = rawQuery[idx+1:]
+	}
+	if idx := strings.Index(rawQuery, "#"); idx >= 0 {
+		rawQuery = rawQuery[:idx]
+	}
+
+	q := NewFMQuery("", "", "")
+	var (
+		sortFields   = map[int]*FMSortField{}
+		compound     string
+		hasCompound  bool
+		qNames       = map[int]string{}
+		qValues      = map[int]string{}
+		simpleFields []FMQueryField
+	)
+
+	for _, part := range strings.Split(rawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		rawKey, rawValue, _ := strings.Cut(part, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			return nil, fmt.Errorf("gofmcon.ParseFMQuery: invalid parameter %q: %w", rawKey, err)
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("gofmcon.ParseFMQuery: invalid value of %s: %w", key, err)
+		}
+
+		if action, ok := fmActions[key]; ok {
+			if q.Action != "" {
+				return nil, fmt.Errorf("gofmcon.ParseFMQuery: more than one action: %s and %s", q.Action, key)
+			}
+			q.Action = action
+			continue
+		}
+
+		if !strings.HasPrefix(key, "-") {
+			simpleFields = append(simpleFields, FMQueryField{Name: key, Value: value})
+			continue
+		}
+
+		switch {
+		case key == "-db":
+			q.Database = value
+		case key == "-lay":
+			q.Layout = value
+		case key == "-lay.response":
+			q.ResponseLayout = value
+		case key == "-recid":
+			q.RecordID, err = strconv.Atoi(value)
+		case key == "-modid":
+			q.ModID, err = strconv.Atoi(value)
+		case key == "-script":
+			q.PostFindScript = value
+		case key == "-script.param":
+			q.PostFindScriptParam = value
+		case key == "-script.prefind":
+			q.PreFindScript = value
+		case key == "-script.prefind.param":
+			q.PreFindScriptParam = value
+		case key == "-script.presort":
+			q.PreSortScript = value
+		case key == "-script.presort.param":
+			q.PreSortScriptParam = value
+		case key == "-max":
+			if value == "all" {
+				q.MaxRecords = fmAllRecords
+			} else {
+				q.MaxRecords, err = strconv.Atoi(value)
+			}
+		case key == "-skip":
+			q.SkipRecords, err = strconv.Atoi(value)
+		case key == "-field":
+			q.ResponseFields = append(q.ResponseFields, value)
+		case key == "-query":
+			compound = value
+			hasCompound = true
+		case strings.HasPrefix(key, "-sortfield."):
+			var n int
+			n, err = strconv.Atoi(strings.TrimPrefix(key, "-sortfield."))
+			sortField(sortFields, n).Name = value
+		case strings.HasPrefix(key, "-sortorder."):
+			var n int
+			n, err = strconv.Atoi(strings.TrimPrefix(key, "-sortorder."))
+			sf := sortField(sortFields, n)
+			switch FMSortOrder(value) {
+			case Ascending, Descending:
+				sf.Order = FMSortOrder(value)
+			default:
+				sf.Order = Custom
+				sf.ValueList = value
+			}
+		case isCompoundFieldKey(key):
+			name := strings.TrimPrefix(key, "-q")
+			if strings.HasSuffix(name, ".value") {
+				var n int
+				n, err = strconv.Atoi(strings.TrimSuffix(name, ".value"))
+				qValues[n] = value
+			} else {
+				var n int
+				n, err = strconv.Atoi(name)
+				qNames[n] = value
+			}
+		default:
+			if q.Query == nil {
+				q.Query = map[string]string{}
+			}
+			q.Query[key] = value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("gofmcon.ParseFMQuery: invalid value of %s: %w", key, err)
+		}
+	}
+
+	if q.Action == "" {
+		return nil, fmt.Errorf("gofmcon.ParseFMQuery: no action")
+	}
+
+	var sortNums []int
+	for n := range sortFields {
+		sortNums = append(sortNums, n)
+	}
+	sort.Ints(sortNums)
+	for _, n := range sortNums {
+		q.SortFields = append(q.SortFields, *sortFields[n])
+	}
+
+	if hasCompound {
+		groups, err := parseCompoundQuery(compound, qNames, qValues)
+		if err != nil {
+			return nil, fmt.Errorf("gofmcon.ParseFMQuery: %w", err)
+		}
+		q.QueryFields = groups
+	} else if len(qNames) > 0 {
+		return nil, fmt.Errorf("gofmcon.ParseFMQuery: find fields without -query")
+	}
+
+	if len(simpleFields) > 0 {
+		q.QueryFields = append(q.QueryFields, FMQueryFieldGroup{Op: And, Fields: simpleFields})
+	}
+
+	return q, nil
+}
+
+func sortField(fields map[int]*FMSortField, n int) *FMSortField {
+	if fields[n] == nil {
+		fields[n] = &FMSortField{}
+	}
+	return fields[n]
+}
+
+func isCompoundFieldKey(key string) bool {
+	if !strings.HasPrefix(key, "-q") || len(key) < 3 {
+		return false
+	}
+	return key[2] >= '0' && key[2] <= '9'
+}
+
+// parseCompoundQuery parses -query value such as (q1,q2);!(q3)
+func parseCompoundQuery(query string, names, values map[int]string) ([]FMQueryFieldGroup, error) {
+	var groups []FMQueryFieldGroup
+	for _, request := range strings.Split(query, ";") {
+		request = strings.TrimSpace(request)
+		if request == "" {
+			continue
+		}
+
+		group := FMQueryFieldGroup{Op: And}
+		if strings.HasPrefix(request, "!") {
+			group.Op = Not
+			request = strings.TrimSpace(request[1:])
+		}
+		if !strings.HasPrefix(request, "(") || !strings.HasSuffix(request, ")") {
+			return nil, fmt.Errorf("invalid request %q in -query", request)
+		}
+
+		for _, ref := range strings.Split(request[1:len(request)-1], ",") {
+			ref = strings.TrimSpace(ref)
+			if !strings.HasPrefix(ref, "q") {
+				return nil, fmt.Errorf("invalid field reference %q in -query", ref)
+			}
+			n, err := strconv.Atoi(ref[1:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid field reference %q in -query", ref)
+			}
+			name, ok := names[n]
+			if !ok {
+				return nil, fmt.Errorf("field %s of -query is missing", ref)
+			}
+			f := parseFindValue(values[n])
+			f.Name = name
+			group.Fields = append(group.Fields, f)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// findValuePatterns are operators of valueWithOp as a prefix and a suffix
+// around the value, more specific ones come first
+var findValuePatterns = []struct {
+	op             FMFieldOp
+	prefix, suffix string
+}{
+	{Contains, "==*", "*"},
+	{EndsWith, "==*", ""},
+	{BeginsWith, "==", "*"},
+	{Equal, "==", ""},
+	{GreaterThanEqual, ">=", ""},
+	{GreaterThan, ">", ""},
+	{LessThanEqual, "<=", ""},
+	{LessThan, "<", ""},
+	{WholeWord, "=", ""},
+}
+
+// parseFindValue reverses valueWithOp. A value which valueWithOp
+// would not produce is returned as a Raw field
+func parseFindValue(raw string) FMQueryField {
+	for _, op := range []FMFieldOp{Empty, NonEmpty, Duplicated, IsToday, InvalidValue} {
+		f := FMQueryField{Op: op}
+		if f.valueWithOp() == raw {
+			return f
+		}
+	}
+
+	for _, p := range findValuePatterns {
+		if len(raw) < len(p.prefix)+len(p.suffix) || !strings.HasPrefix(raw, p.prefix) || !strings.HasSuffix(raw, p.suffix) {
+			continue
+		}
+		f := FMQueryField{Op: p.op, Value: unescapeFindValue(raw[len(p.prefix) : len(raw)-len(p.suffix)])}
+		if f.valueWithOp() == raw {
+			return f
+		}
+	}
+
+	for i := strings.Index(raw, "..."); i >= 0; {
+		f := FMQueryField{Op: InRange, Value: unescapeFindValue(raw[:i]), ValueTo: unescapeFindValue(raw[i+3:])}
+		if f.valueWithOp() == raw {
+			return f
+		}
+		next := strings.Index(raw[i+1:], "...")
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+
+	f := FMQueryField{Value: unescapeFindValue(raw)}
+	if f.valueWithOp() == raw {
+		return f
+	}
+
+	return FMQueryField{Value: raw, Raw: true}
+}
+
+// unescapeFindValue reverses escapeFindValue
+func unescapeFindValue(v string) string {
+	if !strings.Contains(v, `\`) {
+		return v
+	}
+	var b strings.Builder
+	runes := []rune(v)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '\\' && i+1 < len(runes) {
+			i++
+		}
+		b.WriteRune(runes[i])
+	}
+	return b.String()
+}
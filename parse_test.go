@@ -0,0 +1,138 @@
+package gofmcon
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFMQuery(t *testing.T) {
+	q := NewFMQuery("db name", "lay&out", Find).
+		WithResponseLayout("response").
+		WithPreFindScript("prefind", "p1").
+		WithPostFindScript("script", "p&2").
+		WithSortFields(FMSortField{Name: "name", Order: Descending}, CustomSortField("status", "Statuses")).
+		Max(10).
+		Skip(5).
+		WithFieldGroups(
+			FMQueryFieldGroup{Op: And, Fields: []FMQueryField{
+				{Name: "name", Value: "John*", Op: BeginsWith},
+				{Name: "age", Value: "18", ValueTo: "30", Op: InRange},
+			}},
+			FMQueryFieldGroup{Op: Not, Fields: []FMQueryField{{Name: "status", Op: Empty}}},
+		)
+
+	parsed, err := ParseFMQuery("https://fm.example.com/fmi/xml/fmresultset.xml?" + q.QueryString())
+	assert.NoError(t, err)
+	assert.Equal(t, q.QueryString(), parsed.QueryString())
+	assert.Equal(t, q.Database, parsed.Database)
+	assert.Equal(t, q.Layout, parsed.Layout)
+	assert.Equal(t, q.ResponseLayout, parsed.ResponseLayout)
+	assert.Equal(t, q.PostFindScriptParam, parsed.PostFindScriptParam)
+	assert.Equal(t, q.SortFields, parsed.SortFields)
+	assert.Equal(t, 10, parsed.MaxRecords)
+	assert.Equal(t, 5, parsed.SkipRecords)
+	assert.Equal(t, q.QueryFields, parsed.QueryFields)
+
+	parsed, err = ParseFMQuery("-db=db&-lay=lay&-recid=7&-name=x&-field=a&-field=b&first=John&-edit")
+	assert.NoError(t, err)
+	assert.Equal(t, Edit, parsed.Action)
+	assert.Equal(t, 7, parsed.RecordID)
+	assert.Equal(t, []string{"a", "b"}, parsed.ResponseFields)
+	assert.Equal(t, map[string]string{"-name": "x"}, parsed.Query)
+	assert.Equal(t, []FMQueryFieldGroup{{Op: And, Fields: []FMQueryField{{Name: "first", Value: "John"}}}}, parsed.QueryFields)
+
+	parsed, err = ParseFMQuery("-db=db&-lay=lay&-query=(q1)&-q1=name&-q1.value=a*b&-findquery")
+	assert.NoError(t, err)
+	assert.Equal(t, []FMQueryField{{Name: "name", Value: "a*b", Raw: true}}, parsed.QueryFields[0].Fields)
+
+	for _, raw := range []string{
+		"-db=db&-lay=lay",
+		"-db=db&-lay=lay&-find&-findall",
+		"-db=db&-lay=lay&-recid=x&-find",
+		"-db=db&-lay=lay&-query=(q2)&-q1=name&-findquery",
+		"-db=db&-lay=lay&-q1=name&-findquery",
+		"-db=db&-lay=lay&-query=q1&-q1=name&-findquery",
+	} {
+		_, err := ParseFMQuery(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+const parseTestRunes = `ab1 .=/*\!"<>?@#~…≤&+%;,()`
+
+func randomParseString(r *rand.Rand) string {
+	runes := []rune(parseTestRunes)
+	s := make([]rune, r.Intn(6))
+	for i := range s {
+		s[i] = runes[r.Intn(len(runes))]
+	}
+	return string(s)
+}
+
+func randomQuery(r *rand.Rand) *FMQuery {
+	actions := []FMAction{Find, FindAll, FindAny, New, Edit, Delete, Duplicate}
+	ops := []FMFieldOp{"", Equal, Contains, BeginsWith, EndsWith, GreaterThan, GreaterThanEqual,
+		LessThan, LessThanEqual, InRange, Empty, NonEmpty, Duplicated, IsToday, InvalidValue, WholeWord}
+	groupOps := []FMLogicalOp{And, Or, Not}
+
+	q := NewFMQuery("db"+randomParseString(r), "lay"+randomParseString(r), actions[r.Intn(len(actions))])
+	if r.Intn(2) == 0 {
+		q.WithResponseLayout("resp" + randomParseString(r))
+	}
+	if r.Intn(2) == 0 {
+		q.WithPostFindScript("s"+randomParseString(r), randomParseString(r))
+	}
+	if r.Intn(3) == 0 {
+		q.WithPreSortScript("s"+randomParseString(r), randomParseString(r))
+	}
+	if r.Intn(2) == 0 {
+		q.Max(r.Intn(100))
+		q.Skip(r.Intn(100))
+	}
+	for i := r.Intn(3); i > 0; i-- {
+		q.WithResponseFields("r" + randomParseString(r))
+	}
+	if r.Intn(3) == 0 {
+		q.WithParam("-opt"+randomParseString(r), randomParseString(r))
+	}
+	if q.Action == Edit || q.Action == Delete || q.Action == Duplicate || (q.Action == Find && r.Intn(4) == 0) {
+		q.WithRecordID(r.Intn(1000) + 1)
+	}
+	if q.Action == Edit && r.Intn(2) == 0 {
+		q.WithModID(r.Intn(10) + 1)
+	}
+	for i := r.Intn(3); i > 0; i-- {
+		q.WithSortFields(FMSortField{Name: "s" + randomParseString(r), Order: Ascending},
+			CustomSortField("c"+randomParseString(r), "vl"+randomParseString(r)))
+	}
+	for i := r.Intn(4); i > 0; i-- {
+		g := FMQueryFieldGroup{Op: groupOps[r.Intn(len(groupOps))]}
+		for j := r.Intn(3) + 1; j > 0; j-- {
+			g.Fields = append(g.Fields, FMQueryField{
+				Name:    "f" + randomParseString(r),
+				Value:   randomParseString(r),
+				ValueTo: randomParseString(r),
+				Op:      ops[r.Intn(len(ops))],
+				Raw:     r.Intn(4) == 0,
+			})
+		}
+		q.WithFieldGroups(g)
+	}
+	return q
+}
+
+func TestParseFMQueryRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		q := randomQuery(r)
+		parsed, err := ParseFMQuery(q.QueryString())
+		if !assert.NoError(t, err, q.QueryString()) {
+			continue
+		}
+		assert.Equal(t, q.QueryString(), parsed.QueryString())
+		assert.Equal(t, q.Action, parsed.Action)
+		assert.Equal(t, q.ResponseFields, parsed.ResponseFields)
+	}
+}
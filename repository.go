@@ -0,0 +1,254 @@
+package gofmcon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// defaultPageSize is the number of records fetched by one request of All
+const defaultPageSize = 100
+
+// Repository reads and writes records of a layout as structs of type T.
+// Struct fields are mapped to FileMaker fields with the fm tag,
+// fields without the tag are mapped by their Go name
+//
+//	type Customer struct {
+//		ID     int       `fm:",recid"`          // record id
+//		ModID  int       `fm:",modid"`          // modification id
+//		Name   string    `fm:"name"`            // field "name"
+//		Phones []string  `fm:"phone"`           // all repetitions of "phone"
+//		Note   *string   `fm:"note,omitempty"`  // nil when empty, not written when nil
+//		Total  float64   `fm:"total,readonly"`  // read, never written
+//		Since  time.Time `fm:"since"`           // date, time or timestamp
+//		Cache  string    `fm:"-"`               // ignored
+//		Orders []Order   `fm:"orders,portal"`    // records of related set "orders", never written
+//	}
+//
+// Fields of portal records are named with their table, e.g. `fm:"orders::total"`
+//
+// FileMaker errors are returned wrapped, errors.Is matches them with
+// ErrRecordNotFound, ErrRecordModified and ErrRecordInUse
+type Repository[T any] struct {
+	fmc      *FMConnector
+	database string
+	layout   string
+	mapping  *structMapping
+}
+
+// NewRepository creates new Repository object for the layout.
+// It returns an error if T is not a struct or its fm tags are invalid
+func NewRepository[T any](fmc *FMConnector, database, layout string) (*Repository[T], error) {
+	m, err := mappingOf(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, fmt.Errorf("gofmcon.NewRepository: %w", err)
+	}
+
+	return &Repository[T]{
+		fmc:      fmc,
+		database: database,
+		layout:   layout,
+		mapping:  m,
+	}, nil
+}
+
+// Query creates new FMQuery for the database and layout of the repository
+func (r *Repository[T]) Query(action FMAction) *FMQuery {
+	return NewFMQuery(r.database, r.layout, action)
+}
+
+// Get returns the record with the record id
+func (r *Repository[T]) Get(ctx context.Context, id int) (T, error) {
+	v, err := r.one(ctx, r.Query(Find).WithRecordID(id))
+	if err != nil {
+		return v, fmt.Errorf("gofmcon.Repository.Get: %w", err)
+	}
+	return v, nil
+}
+
+// FindWhere returns all records matching the expression sorted by the sort
+// fields. No matching records is not an error, an empty slice is returned
+func (r *Repository[T]) FindWhere(ctx context.Context, e Expr, sortFields ...FMSortField) ([]T, error) {
+	q := r.Query(Find).WithExpr(e).WithSortFields(sortFields...)
+	values, err := r.find(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("gofmcon.Repository.FindWhere: %w", err)
+	}
+	return values, nil
+}
+
+// All returns an iterator over all records of the layout,
+// fetched by pageSize records at a time
+func (r *Repository[T]) All(ctx context.Context, pageSize int) *Iterator[T] {
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	return &Iterator[T]{repo: r, ctx: ctx, pageSize: pageSize}
+}
+
+// Create creates a new record from v and returns it as it was saved,
+// with the record id and the values of calculated fields
+func (r *Repository[T]) Create(ctx context.Context, v T) (T, error) {
+	fields, err := r.mapping.encode(reflect.ValueOf(&v).Elem())
+	if err != nil {
+		return v, fmt.Errorf("gofmcon.Repository.Create: %w", err)
+	}
+
+	created, err := r.one(ctx, r.Query(New).WithFields(fields...))
+	if err != nil {
+		return v, fmt.Errorf("gofmcon.Repository.Create: %w", err)
+	}
+	return created, nil
+}
+
+// Update writes all writable fields of v to the record with its record id.
+// If T has a modid field with a value other than 0, the record is only
+// updated if it wasn't modified since, otherwise the returned error
+// matches ErrRecordModified
+func (r *Repository[T]) Update(ctx context.Context, v T) (T, error) {
+	if r.mapping.recID == nil {
+		return v, fmt.Errorf("gofmcon.Repository.Update: %s has no recid field", r.mapping.typ)
+	}
+
+	rv := reflect.ValueOf(&v).Elem()
+	fields, err := r.mapping.encode(rv)
+	if err != nil {
+		return v, fmt.Errorf("gofmcon.Repository.Update: %w", err)
+	}
+
+	q := r.Query(Edit).
+		WithRecordID(int(rv.FieldByIndex(r.mapping.recID).Int())).
+		WithFields(fields...)
+	if r.mapping.modID != nil {
+		q.WithModID(int(rv.FieldByIndex(r.mapping.modID).Int()))
+	}
+
+	updated, err := r.one(ctx, q)
+	if err != nil {
+		return v, fmt.Errorf("gofmcon.Repository.Update: %w", err)
+	}
+	return updated, nil
+}
+
+// Delete deletes the record with the record id
+func (r *Repository[T]) Delete(ctx context.Context, id int) error {
+	_, err := r.fmc.Query(ctx, r.Query(Delete).WithRecordID(id))
+	if err != nil {
+		return fmt.Errorf("gofmcon.Repository.Delete: %w", err)
+	}
+	return nil
+}
+
+// Duplicate duplicates the record with the record id and returns the new record
+func (r *Repository[T]) Duplicate(ctx context.Context, id int) (T, error) {
+	v, err := r.one(ctx, r.Query(Duplicate).WithRecordID(id))
+	if err != nil {
+		return v, fmt.Errorf("gofmcon.Repository.Duplicate: %w", err)
+	}
+	return v, nil
+}
+
+// one sends the query and returns the first record of the response
+func (r *Repository[T]) one(ctx context.Context, q *FMQuery) (T, error) {
+	var v T
+	rs, err := r.fmc.Query(ctx, q)
+	if err != nil {
+		return v, err
+	}
+	if rs.Resultset == nil || len(rs.Resultset.Records) == 0 {
+		return v, ErrRecordNotFound
+	}
+	return r.decode(rs.Resultset.Records[0])
+}
+
+// find sends the query and returns all records of the response,
+// no matching records is not an error
+func (r *Repository[T]) find(ctx context.Context, q *FMQuery) ([]T, error) {
+	rs, err := r.fmc.Query(ctx, q)
+	if errors.Is(err, ErrRecordNotFound) {
+		return []T{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rs.Resultset == nil {
+		return []T{}, nil
+	}
+
+	values := make([]T, 0, len(rs.Resultset.Records))
+	for _, rec := range rs.Resultset.Records {
+		v, err := r.decode(rec)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (r *Repository[T]) decode(rec *Record) (T, error) {
+	var v T
+	err := r.mapping.decode(rec, reflect.ValueOf(&v).Elem())
+	if err != nil {
+		return v, fmt.Errorf("record %d: %w", rec.ID, err)
+	}
+	return v, nil
+}
+
+// Iterator iterates over records fetched page by page
+//
+//	it := repo.All(ctx, 100)
+//	for it.Next() {
+//		v := it.Value()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type Iterator[T any] struct {
+	repo     *Repository[T]
+	ctx      context.Context
+	pageSize int
+	skip     int
+	page     []T
+	pos      int
+	value    T
+	done     bool
+	err      error
+}
+
+// Next advances to the next record, fetching the next page when needed.
+// It returns false when there are no more records or an error occurred
+func (it *Iterator[T]) Next() bool {
+	for it.pos >= len(it.page) {
+		if it.done || it.err != nil {
+			return false
+		}
+
+		q := it.repo.Query(FindAll).Max(it.pageSize).Skip(it.skip)
+		page, err := it.repo.find(it.ctx, q)
+		if err != nil {
+			it.err = fmt.Errorf("gofmcon.Iterator: %w", err)
+			return false
+		}
+		it.page = page
+		it.pos = 0
+		it.skip += len(page)
+		if len(page) < it.pageSize {
+			it.done = true
+		}
+	}
+
+	it.value = it.page[it.pos]
+	it.pos++
+	return true
+}
+
+// Value returns the current record
+func (it *Iterator[T]) Value() T {
+	return it.value
+}
+
+// Err returns the error which stopped the iteration
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
@@ -0,0 +1,459 @@
+package gofmcon
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRecord struct {
+	id     int
+	modID  int
+	fields map[string][]string
+}
+
+// fakeFileMaker is an in-memory FileMaker layout answering
+// XML requests parsed with ParseFMQuery
+type fakeFileMaker struct {
+	mu      sync.Mutex
+	nextID  int
+	records []*fakeRecord
+	queries []*FMQuery
+	// fields, if set, are the field definitions of the layout,
+	// otherwise the fields of the returned records are text fields
+	fields FieldsDefinitions
+}
+
+func newFakeFileMaker(t *testing.T) (*fakeFileMaker, *FMConnector) {
+	fake := &fakeFileMaker{nextID: 1}
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		q, err := ParseFMQuery(r.URL.RawQuery)
+		if err != nil {
+			t.Errorf("invalid request %s: %v", r.URL.RawQuery, err)
+			return
+		}
+		_, _ = w.Write([]byte(fake.handle(q)))
+	})
+	return fake, conn
+}
+
+var repetitionName = regexp.MustCompile(`^(.*)\((\d+)\)$`)
+
+func (f *fakeFileMaker) add(fields map[string]string) *fakeRecord {
+	rec := &fakeRecord{id: f.nextID, fields: map[string][]string{}}
+	f.nextID++
+	for name, value := range fields {
+		rec.fields[name] = []string{value}
+	}
+	f.records = append(f.records, rec)
+	return rec
+}
+
+func (f *fakeFileMaker) get(id int) *fakeRecord {
+	for _, rec := range f.records {
+		if rec.id == id {
+			return rec
+		}
+	}
+	return nil
+}
+
+func (f *fakeFileMaker) handle(q *FMQuery) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries = append(f.queries, q)
+
+	switch q.Action {
+	case New:
+		rec := f.add(nil)
+		setFakeFields(rec, q)
+		rec.modID = 1
+		return f.xml(0, rec)
+	case Edit, Delete, Duplicate:
+		rec := f.get(q.RecordID)
+		if rec == nil {
+			return f.xml(101)
+		}
+		switch q.Action {
+		case Edit:
+			if q.ModID != 0 && q.ModID != rec.modID {
+				return f.xml(306)
+			}
+			setFakeFields(rec, q)
+			rec.modID++
+			return f.xml(0, rec)
+		case Delete:
+			for i, r := range f.records {
+				if r == rec {
+					f.records = append(f.records[:i], f.records[i+1:]...)
+					break
+				}
+			}
+			return f.xml(0)
+		default:
+			dup := f.add(nil)
+			for name, values := range rec.fields {
+				dup.fields[name] = append([]string(nil), values...)
+			}
+			return f.xml(0, dup)
+		}
+	case Find, FindAll:
+		if q.RecordID != fmNoRecordID {
+			rec := f.get(q.RecordID)
+			if rec == nil {
+				return f.xml(101)
+			}
+			return f.xml(0, rec)
+		}
+
+		var found []*fakeRecord
+		for _, rec := range f.records {
+			if q.Action == FindAll || fakeMatches(rec, q.QueryFields) {
+				found = append(found, rec)
+			}
+		}
+		if len(q.SortFields) > 0 {
+			name := q.SortFields[0].Name
+			sort.SliceStable(found, func(i, j int) bool {
+				less := fakeValue(found[i], name) < fakeValue(found[j], name)
+				if q.SortFields[0].Order == Descending {
+					return fakeValue(found[i], name) > fakeValue(found[j], name)
+				}
+				return less
+			})
+		}
+		if q.SkipRecords < len(found) {
+			found = found[q.SkipRecords:]
+		} else {
+			found = nil
+		}
+		if q.MaxRecords != fmAllRecords && q.MaxRecords < len(found) {
+			found = found[:q.MaxRecords]
+		}
+		if len(found) == 0 {
+			return f.xml(401)
+		}
+		return f.xml(0, found...)
+	}
+	return f.xml(3)
+}
+
+func setFakeFields(rec *fakeRecord, q *FMQuery) {
+	for _, g := range q.QueryFields {
+		for _, field := range g.Fields {
+			name, rep := field.Name, 1
+			if m := repetitionName.FindStringSubmatch(field.Name); m != nil {
+				name = m[1]
+				_, _ = fmt.Sscan(m[2], &rep)
+			}
+			values := rec.fields[name]
+			for len(values) < rep {
+				values = append(values, "")
+			}
+			values[rep-1] = field.Value
+			rec.fields[name] = values
+		}
+	}
+}
+
+func fakeValue(rec *fakeRecord, name string) string {
+	values := rec.fields[name]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// fakeMatches supports only equality, processing requests in order as FileMaker does
+func fakeMatches(rec *fakeRecord, groups []FMQueryFieldGroup) bool {
+	found := len(groups) > 0 && groups[0].Op == Not
+	for _, g := range groups {
+		all := true
+		for _, field := range g.Fields {
+			all = all && strings.EqualFold(fakeValue(rec, field.Name), field.Value)
+		}
+		if g.Op == Not {
+			found = found && !all
+		} else {
+			found = found || all
+		}
+	}
+	return found
+}
+
+func (f *fakeFileMaker) xml(code int, records ...*fakeRecord) string {
+	fields := f.fields
+	if fields == nil {
+		names := map[string]int{}
+		for _, rec := range records {
+			for name, values := range rec.fields {
+				if len(values) > names[name] {
+					names[name] = len(values)
+				}
+			}
+		}
+		for _, name := range sortedFakeNames(names) {
+			fields = append(fields, FieldDefinition{Name: name, Type: TypeText, MaxRepeat: names[name]})
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<fmresultset><error code="%d"/><metadata>`, code)
+	for _, fd := range fields {
+		fmt.Fprintf(&b, `<field-definition name="%s" result="%s" max-repeat="%d"/>`, xmlEscape(fd.Name), fd.Type, fd.MaxRepeat)
+	}
+	fmt.Fprintf(&b, `</metadata><resultset count="%d" fetch-size="%d">`, len(records), len(records))
+	for _, rec := range records {
+		fmt.Fprintf(&b, `<record record-id="%d" mod-id="%d">`, rec.id, rec.modID)
+		for _, fd := range fields {
+			fmt.Fprintf(&b, `<field name="%s">`, xmlEscape(fd.Name))
+			for _, v := range rec.fields[fd.Name] {
+				fmt.Fprintf(&b, `<data>%s</data>`, xmlEscape(v))
+			}
+			b.WriteString(`</field>`)
+		}
+		b.WriteString(`</record>`)
+	}
+	b.WriteString(`</resultset></fmresultset>`)
+	return b.String()
+}
+
+func sortedFakeNames(names map[string]int) []string {
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+type testCustomer struct {
+	ID     int       `fm:",recid"`
+	ModID  int       `fm:",modid"`
+	Name   string    `fm:"name"`
+	Phones []string  `fm:"phone"`
+	Age    *int      `fm:"age,omitempty"`
+	Total  float64   `fm:"total,readonly"`
+	Since  time.Time `fm:"since"`
+	VIP    bool
+	Cache  string `fm:"-"`
+}
+
+func TestRepository(t *testing.T) {
+	fake, conn := newFakeFileMaker(t)
+	repo, err := NewRepository[testCustomer](conn, "db", "customers")
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	since := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	created, err := repo.Create(ctx, testCustomer{Name: "John", Phones: []string{"1", "2"}, Total: 10, Since: since, VIP: true, Cache: "x"})
+	assert.NoError(t, err)
+	assert.Equal(t, testCustomer{ID: 1, ModID: 1, Name: "John", Phones: []string{"1", "2"}, Since: since, VIP: true}, created)
+	assert.Equal(t, []string{"01/02/2020"}, fake.records[0].fields["since"])
+	assert.Equal(t, []string{"1"}, fake.records[0].fields["VIP"])
+	assert.NotContains(t, fake.records[0].fields, "total")
+	assert.NotContains(t, fake.records[0].fields, "age")
+
+	fake.records[0].fields["total"] = []string{"12.5"}
+	fake.records[0].fields["age"] = []string{"42"}
+	got, err := repo.Get(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 12.5, got.Total)
+	assert.Equal(t, 42, *got.Age)
+
+	got.Name = "Jane"
+	updated, err := repo.Update(ctx, got)
+	assert.NoError(t, err)
+	assert.Equal(t, "Jane", updated.Name)
+	assert.Equal(t, 2, updated.ModID)
+
+	// got has the old mod id
+	_, err = repo.Update(ctx, got)
+	assert.True(t, errors.Is(err, ErrRecordModified))
+	var fmErr *FMError
+	if assert.True(t, errors.As(err, &fmErr)) {
+		assert.Equal(t, 306, fmErr.Code)
+	}
+
+	dup, err := repo.Duplicate(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, dup.ID)
+	assert.Equal(t, "Jane", dup.Name)
+
+	found, err := repo.FindWhere(ctx, FieldExpr("name", Eq("Jane")), FMSortField{Name: "name", Order: Ascending})
+	assert.NoError(t, err)
+	assert.Len(t, found, 2)
+	found, err = repo.FindWhere(ctx, FieldExpr("name", Eq("Nobody")))
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+
+	assert.NoError(t, repo.Delete(ctx, 2))
+	_, err = repo.Get(ctx, 2)
+	assert.True(t, errors.Is(err, ErrRecordNotFound))
+	assert.True(t, errors.Is(repo.Delete(ctx, 2), ErrRecordNotFound))
+}
+
+func TestRepositoryAll(t *testing.T) {
+	fake, conn := newFakeFileMaker(t)
+	for i := 0; i < 5; i++ {
+		fake.add(map[string]string{"name": fmt.Sprint("c", i)})
+	}
+	repo, err := NewRepository[testCustomer](conn, "db", "customers")
+	assert.NoError(t, err)
+
+	var names []string
+	it := repo.All(context.Background(), 2)
+	for it.Next() {
+		names = append(names, it.Value().Name)
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, []string{"c0", "c1", "c2", "c3", "c4"}, names)
+	assert.Len(t, fake.queries, 3)
+
+	fake.records = nil
+	it = repo.All(context.Background(), 2)
+	assert.False(t, it.Next())
+	assert.NoError(t, it.Err())
+}
+
+func TestRepositoryMapping(t *testing.T) {
+	_, err := NewRepository[int](nil, "db", "lay")
+	assert.Error(t, err)
+	_, err = NewRepository[struct {
+		ID string `fm:",recid"`
+	}](nil, "db", "lay")
+	assert.Error(t, err)
+	_, err = NewRepository[struct {
+		Name string `fm:"name,unknown"`
+	}](nil, "db", "lay")
+	assert.Error(t, err)
+	_, err = NewRepository[struct {
+		Tags map[string]string
+	}](nil, "db", "lay")
+	assert.Error(t, err)
+
+	repo, err := NewRepository[struct {
+		Name string
+	}](nil, "db", "lay")
+	assert.NoError(t, err)
+	_, err = repo.Update(context.Background(), struct{ Name string }{})
+	assert.Error(t, err)
+
+	var c testCustomer
+	m, err := mappingOf(reflect.TypeOf(c))
+	assert.NoError(t, err)
+	rec := &Record{ID: 3, ModID: 4, Fields: []*Field{
+		{Name: "Name", Data: []string{" John "}},
+		{Name: "age", Data: []string{"1.0"}},
+		{Name: "since", Data: []string{"01/02/2020 10:11:12"}},
+		{Name: "vip", Data: []string{""}},
+	}}
+	assert.NoError(t, m.decode(rec, reflect.ValueOf(&c).Elem()))
+	assert.Equal(t, " John ", c.Name)
+	assert.Equal(t, 1, *c.Age)
+	assert.Equal(t, time.Date(2020, 1, 2, 10, 11, 12, 0, time.UTC), c.Since)
+	assert.Equal(t, 3, c.ID)
+	assert.Equal(t, 4, c.ModID)
+
+	type order struct {
+		ID    int     `fm:",recid"`
+		Total float64 `fm:"orders::total"`
+	}
+	var withPortal struct {
+		Name   string  `fm:"name"`
+		Orders []order `fm:"Orders,portal"`
+	}
+	pm, err := mappingOf(reflect.TypeOf(withPortal))
+	assert.NoError(t, err)
+	portalRec := &Record{ID: 1, RelatedSet: []*RelatedSet{{Table: "orders", Records: []*Record{
+		{ID: 7, Fields: []*Field{{Name: "orders::total", Data: []string{"1.5"}}}},
+		{ID: 8, Fields: []*Field{{Name: "orders::total", Data: []string{"2"}}}},
+	}}}}
+	assert.NoError(t, pm.decode(portalRec, reflect.ValueOf(&withPortal).Elem()))
+	assert.Equal(t, []order{{ID: 7, Total: 1.5}, {ID: 8, Total: 2}}, withPortal.Orders)
+	fields, err := pm.encode(reflect.ValueOf(withPortal))
+	assert.NoError(t, err)
+	assert.Equal(t, []FMQueryField{{Name: "name"}}, fields)
+
+	_, err = mappingOf(reflect.TypeOf(struct {
+		Orders []string `fm:"orders,portal"`
+	}{}))
+	assert.Error(t, err)
+
+	rec.Fields[1].Data = []string{"x"}
+	assert.Error(t, m.decode(rec, reflect.ValueOf(&c).Elem()))
+}
+
+type treeNode struct {
+	Name string     `fm:"name"`
+	Kids []treeNode `fm:"kids,portal"`
+}
+
+type recursiveA struct {
+	Name string       `fm:"a"`
+	Bs   []recursiveB `fm:"bs,portal"`
+}
+
+type recursiveB struct {
+	Name string       `fm:"b"`
+	As   []recursiveA `fm:"as,portal"`
+	Tags map[string]string
+}
+
+type personRecord struct {
+	Name string      `fm:"name"`
+	Pets []petRecord `fm:"pets,portal"`
+}
+
+type petRecord struct {
+	Name   string         `fm:"pets::name"`
+	Owners []personRecord `fm:"owners,portal"`
+}
+
+func TestRecursivePortalMapping(t *testing.T) {
+	m, err := mappingOf(reflect.TypeOf(treeNode{}))
+	assert.NoError(t, err)
+	assert.Same(t, m, m.fields[1].portal)
+
+	var node treeNode
+	rec := &Record{ID: 1, Fields: []*Field{{Name: "name", Data: []string{"root"}}}, RelatedSet: []*RelatedSet{{Table: "kids", Records: []*Record{
+		{ID: 2, Fields: []*Field{{Name: "name", Data: []string{"kid"}}}},
+	}}}}
+	assert.NoError(t, m.decode(rec, reflect.ValueOf(&node).Elem()))
+	assert.Equal(t, treeNode{Name: "root", Kids: []treeNode{{Name: "kid"}}}, node)
+
+	_, err = NewRepository[treeNode](nil, "db", "nodes")
+	assert.NoError(t, err)
+	s, err := SchemaFromStruct("nodes", treeNode{})
+	assert.NoError(t, err)
+	assert.Equal(t, []FieldSchema{{Name: "name"}, {Name: "name"}}, s.Fields)
+
+	pm, err := mappingOf(reflect.TypeOf(personRecord{}))
+	assert.NoError(t, err)
+	assert.Same(t, pm, pm.fields[1].portal.fields[1].portal)
+
+	// mutually recursive types fail with the error of either of them
+	_, err = mappingOf(reflect.TypeOf(recursiveA{}))
+	assert.Error(t, err)
+	_, ok := structMappings.Load(reflect.TypeOf(recursiveA{}))
+	assert.False(t, ok)
+	_, err = NewRepository[recursiveB](nil, "db", "b")
+	assert.Error(t, err)
+}
@@ -0,0 +1,284 @@
+package gofmcon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// LayoutSchema is the expected definition of the fields of a layout.
+// It can be built from a struct with SchemaFromStruct or loaded from
+// a YAML or JSON file with LoadSchema, e.g.
+//
+//	layout: customers
+//	fields:
+//	  - name: name
+//	    type: text
+//	    notEmpty: true
+//	  - name: phone
+//	    maxRepeat: 3
+type LayoutSchema struct {
+	Layout string        `json:"layout" yaml:"layout"`
+	Fields []FieldSchema `json:"fields" yaml:"fields"`
+}
+
+// FieldSchema is the expected definition of a field.
+// Empty properties are not compared
+type FieldSchema struct {
+	Name      string    `json:"name" yaml:"name"`
+	Type      FieldType `json:"type,omitempty" yaml:"type,omitempty"`
+	MaxRepeat int       `json:"maxRepeat,omitempty" yaml:"maxRepeat,omitempty"`
+	Global    *bool     `json:"global,omitempty" yaml:"global,omitempty"`
+	NotEmpty  *bool     `json:"notEmpty,omitempty" yaml:"notEmpty,omitempty"`
+}
+
+// FieldChange is a property of a field which differs from the expected one
+type FieldChange struct {
+	Name     string
+	Property string
+	Expected string
+	Actual   string
+}
+
+// SchemaDiff is the difference between the expected and the actual fields of a layout
+type SchemaDiff struct {
+	Layout string
+	// Added are fields on the layout which are not expected
+	Added []FieldDefinition
+	// Removed are expected fields missing on the layout
+	Removed []FieldSchema
+	// Changed are expected fields defined differently on the layout
+	Changed []FieldChange
+}
+
+// Empty reports whether the layout matches the schema exactly
+func (d SchemaDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Breaking reports whether expected fields were removed or changed,
+// fields added to the layout don't break anything
+func (d SchemaDiff) Breaking() bool {
+	return len(d.Removed) > 0 || len(d.Changed) > 0
+}
+
+func (d SchemaDiff) String() string {
+	var lines []string
+	for _, f := range d.Removed {
+		lines = append(lines, "removed field "+f.Name)
+	}
+	for _, c := range d.Changed {
+		lines = append(lines, fmt.Sprintf("changed %s of field %s from %s to %s", c.Property, c.Name, c.Expected, c.Actual))
+	}
+	for _, f := range d.Added {
+		lines = append(lines, "added field "+f.Name)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Err returns SchemaDriftError if the difference is breaking
+func (d SchemaDiff) Err() error {
+	if !d.Breaking() {
+		return nil
+	}
+	return &SchemaDriftError{Diff: d}
+}
+
+// SchemaDriftError is returned when the fields of a layout
+// no longer match the expected schema
+type SchemaDriftError struct {
+	Diff SchemaDiff
+}
+
+func (e *SchemaDriftError) Error() string {
+	return fmt.Sprintf("layout %s does not match the schema: %s", e.Diff.Layout, strings.ReplaceAll(e.Diff.String(), "\n", "; "))
+}
+
+// CompareSchema compares the actual field definitions of a layout to the
+// expected schema. Field names are compared ignoring case as FileMaker does
+func CompareSchema(expected LayoutSchema, actual FieldsDefinitions) SchemaDiff {
+	diff := SchemaDiff{Layout: expected.Layout}
+
+	seen := map[string]bool{}
+	for _, fs := range expected.Fields {
+		seen[strings.ToLower(fs.Name)] = true
+
+		fd, ok := actual.definition(fs.Name)
+		if !ok {
+			diff.Removed = append(diff.Removed, fs)
+			continue
+		}
+
+		change := func(property, expected, actual string) {
+			diff.Changed = append(diff.Changed, FieldChange{Name: fs.Name, Property: property, Expected: expected, Actual: actual})
+		}
+		if fs.Type != "" && fs.Type != fd.Type {
+			change("type", string(fs.Type), string(fd.Type))
+		}
+		if fs.MaxRepeat != 0 && fs.MaxRepeat != fd.MaxRepeat {
+			change("max-repeat", strconv.Itoa(fs.MaxRepeat), strconv.Itoa(fd.MaxRepeat))
+		}
+		if fs.Global != nil && *fs.Global != fd.Global {
+			change("global", strconv.FormatBool(*fs.Global), strconv.FormatBool(fd.Global))
+		}
+		if fs.NotEmpty != nil && *fs.NotEmpty != fd.NotEmpty {
+			change("not-empty", strconv.FormatBool(*fs.NotEmpty), strconv.FormatBool(fd.NotEmpty))
+		}
+	}
+
+	for _, fd := range actual {
+		if !seen[strings.ToLower(fd.Name)] {
+			diff.Added = append(diff.Added, fd)
+		}
+	}
+
+	return diff
+}
+
+func (fds FieldsDefinitions) definition(name string) (FieldDefinition, bool) {
+	for _, fd := range fds {
+		if strings.EqualFold(fd.Name, name) {
+			return fd, true
+		}
+	}
+	return FieldDefinition{}, false
+}
+
+// SchemaFromLayout returns the schema describing the field definitions exactly,
+// e.g. to record the schema of a layout into a file
+func SchemaFromLayout(layout string, fds FieldsDefinitions) LayoutSchema {
+	s := LayoutSchema{Layout: layout}
+	for _, fd := range fds {
+		global, notEmpty := fd.Global, fd.NotEmpty
+		s.Fields = append(s.Fields, FieldSchema{
+			Name:      fd.Name,
+			Type:      fd.Type,
+			MaxRepeat: fd.MaxRepeat,
+			Global:    &global,
+			NotEmpty:  &notEmpty,
+		})
+	}
+	return s
+}
+
+// SchemaFromStruct returns the schema of the fields the struct is mapped to
+// with fm tags, see Repository. Numeric fields are expected to be number
+// fields, types of other fields are not compared
+func SchemaFromStruct(layout string, v interface{}) (LayoutSchema, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return LayoutSchema{}, errors.New("gofmcon.SchemaFromStruct: nil value")
+	}
+
+	m, err := mappingOf(t)
+	if err != nil {
+		return LayoutSchema{}, fmt.Errorf("gofmcon.SchemaFromStruct: %w", err)
+	}
+
+	s := LayoutSchema{Layout: layout}
+	s.Fields = m.schemaFields(true)
+	return s, nil
+}
+
+// schemaFields returns the fields of the mapping, and of its portals if
+// withPortals is set. Related records have no portals of their own
+func (m *structMapping) schemaFields(withPortals bool) []FieldSchema {
+	var fields []FieldSchema
+	for _, f := range m.fields {
+		if f.portal != nil {
+			if withPortals {
+				fields = append(fields, f.portal.schemaFields(false)...)
+			}
+			continue
+		}
+		fs := FieldSchema{Name: f.name}
+		if isNumberType(m.typ.FieldByIndex(f.index).Type) {
+			fs.Type = TypeNumber
+		}
+		fields = append(fields, fs)
+	}
+	return fields
+}
+
+func isNumberType(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// ParseSchema parses a schema in YAML or JSON
+func ParseSchema(data []byte) (LayoutSchema, error) {
+	var s LayoutSchema
+	err := yaml.UnmarshalStrict(data, &s)
+	if err != nil {
+		return s, fmt.Errorf("gofmcon.ParseSchema: %w", err)
+	}
+	for i, f := range s.Fields {
+		if f.Name == "" {
+			return s, fmt.Errorf("gofmcon.ParseSchema: field %d has no name", i+1)
+		}
+	}
+	return s, nil
+}
+
+// LoadSchema reads a schema from a YAML or JSON file
+func LoadSchema(path string) (LayoutSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return LayoutSchema{}, fmt.Errorf("gofmcon.LoadSchema: %w", err)
+	}
+	return ParseSchema(data)
+}
+
+// FieldDefinitions returns definitions of all fields in the response,
+// including fields of the related set
+func (rs *FMResultset) FieldDefinitions() FieldsDefinitions {
+	if rs.MetaData == nil {
+		return nil
+	}
+	return rs.MetaData.getAllFieldDefinitions()
+}
+
+// LayoutFields fetches definitions of the fields on the layout
+func (fmc *FMConnector) LayoutFields(ctx context.Context, database, layout string) (FieldsDefinitions, error) {
+	rs, err := fmc.Query(ctx, NewFMQuery(database, layout, FindAll).Max(0))
+	// metadata is returned even if the layout has no records
+	if err != nil && !errors.Is(err, ErrRecordNotFound) {
+		return nil, fmt.Errorf("gofmcon.LayoutFields: %w", err)
+	}
+	return rs.FieldDefinitions(), nil
+}
+
+// CheckSchema compares fields of the layout of the schema to the schema.
+// The returned error is SchemaDriftError when the difference is breaking
+func (fmc *FMConnector) CheckSchema(ctx context.Context, database string, expected LayoutSchema) (SchemaDiff, error) {
+	fds, err := fmc.LayoutFields(ctx, database, expected.Layout)
+	if err != nil {
+		return SchemaDiff{}, fmt.Errorf("gofmcon.CheckSchema: %w", err)
+	}
+
+	diff := CompareSchema(expected, fds)
+	if err := diff.Err(); err != nil {
+		return diff, fmt.Errorf("gofmcon.CheckSchema: %w", err)
+	}
+	return diff, nil
+}
@@ -0,0 +1,219 @@
+package gofmcon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const schemaXML = `<fmresultset><error code="0"/>
+<metadata>
+<field-definition name="name" result="text" type="normal" max-repeat="1" global="no" not-empty="yes"/>
+<field-definition name="phone" result="text" type="normal" max-repeat="2" global="no" not-empty="no"/>
+<field-definition name="total" result="number" type="calculation" max-repeat="1" global="no" not-empty="no"/>
+<field-definition name="created" result="timestamp" type="normal" max-repeat="1" global="no" not-empty="no"/>
+<relatedset-definition table="orders">
+<field-definition name="orders::total" result="number" type="normal" max-repeat="1" global="no" not-empty="no"/>
+</relatedset-definition>
+</metadata>
+<resultset count="0" fetch-size="0"></resultset>
+</fmresultset>`
+
+func TestCompareSchema(t *testing.T) {
+	yes := true
+	expected := LayoutSchema{
+		Layout: "customers",
+		Fields: []FieldSchema{
+			{Name: "Name", Type: TypeText, NotEmpty: &yes},
+			{Name: "phone", MaxRepeat: 3},
+			{Name: "total", Type: TypeText, Global: &yes},
+			{Name: "email"},
+		},
+	}
+	actual := FieldsDefinitions{
+		{Name: "name", Type: TypeText, MaxRepeat: 1, NotEmpty: true},
+		{Name: "phone", Type: TypeText, MaxRepeat: 2},
+		{Name: "total", Type: TypeNumber, MaxRepeat: 1},
+		{Name: "created", Type: TypeTimestamp, MaxRepeat: 1},
+	}
+
+	diff := CompareSchema(expected, actual)
+	assert.Equal(t, "customers", diff.Layout)
+	assert.Equal(t, []FieldSchema{{Name: "email"}}, diff.Removed)
+	assert.Equal(t, []FieldChange{
+		{Name: "phone", Property: "max-repeat", Expected: "3", Actual: "2"},
+		{Name: "total", Property: "type", Expected: "text", Actual: "number"},
+		{Name: "total", Property: "global", Expected: "true", Actual: "false"},
+	}, diff.Changed)
+	assert.Equal(t, []FieldDefinition{actual[3]}, diff.Added)
+	assert.False(t, diff.Empty())
+	assert.True(t, diff.Breaking())
+	assert.Equal(t, "removed field email\n"+
+		"changed max-repeat of field phone from 3 to 2\n"+
+		"changed type of field total from text to number\n"+
+		"changed global of field total from true to false\n"+
+		"added field created", diff.String())
+
+	var drift *SchemaDriftError
+	assert.True(t, errors.As(diff.Err(), &drift))
+
+	// added fields only are not breaking
+	diff = CompareSchema(LayoutSchema{Layout: "customers", Fields: []FieldSchema{{Name: "name"}}}, actual)
+	assert.Len(t, diff.Added, 3)
+	assert.False(t, diff.Empty())
+	assert.False(t, diff.Breaking())
+	assert.NoError(t, diff.Err())
+
+	// the schema of a layout matches the layout
+	diff = CompareSchema(SchemaFromLayout("customers", actual), actual)
+	assert.True(t, diff.Empty())
+}
+
+func TestSchemaFromStruct(t *testing.T) {
+	type order struct {
+		Total float64 `fm:"orders::total"`
+	}
+	type customer struct {
+		testCustomer
+		Orders []order `fm:"orders,portal"`
+	}
+
+	s, err := SchemaFromStruct("customers", &testCustomer{})
+	assert.NoError(t, err)
+	assert.Equal(t, LayoutSchema{
+		Layout: "customers",
+		Fields: []FieldSchema{
+			{Name: "name"},
+			{Name: "phone"},
+			{Name: "age", Type: TypeNumber},
+			{Name: "total", Type: TypeNumber},
+			{Name: "since"},
+			{Name: "VIP"},
+		},
+	}, s)
+
+	s, err = SchemaFromStruct("customers", customer{})
+	assert.NoError(t, err)
+	assert.Equal(t, FieldSchema{Name: "orders::total", Type: TypeNumber}, s.Fields[len(s.Fields)-1])
+
+	_, err = SchemaFromStruct("customers", 1)
+	assert.Error(t, err)
+	_, err = SchemaFromStruct("customers", nil)
+	assert.Error(t, err)
+}
+
+func TestParseSchema(t *testing.T) {
+	yes, no := true, false
+	expected := LayoutSchema{
+		Layout: "customers",
+		Fields: []FieldSchema{
+			{Name: "name", Type: TypeText, NotEmpty: &yes},
+			{Name: "phone", MaxRepeat: 2, Global: &no},
+		},
+	}
+
+	s, err := ParseSchema([]byte(`
+layout: customers
+fields:
+  - name: name
+    type: text
+    notEmpty: true
+  - name: phone
+    maxRepeat: 2
+    global: false
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, s)
+
+	s, err = ParseSchema([]byte(`{
+	"layout": "customers",
+	"fields": [
+		{"name": "name", "type": "text", "notEmpty": true},
+		{"name": "phone", "maxRepeat": 2, "global": false}
+	]
+}`))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, s)
+
+	_, err = ParseSchema([]byte("layout: customers\nfields:\n  - name: name\n    required: true\n"))
+	assert.Error(t, err)
+	_, err = ParseSchema([]byte("layout: customers\nfields:\n  - type: text\n"))
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "customers.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("layout: customers\nfields:\n  - name: name\n"), 0o600))
+	s, err = LoadSchema(path)
+	assert.NoError(t, err)
+	assert.Equal(t, LayoutSchema{Layout: "customers", Fields: []FieldSchema{{Name: "name"}}}, s)
+
+	_, err = LoadSchema(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestCheckSchema(t *testing.T) {
+	var max string
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		max = r.URL.Query().Get("-max")
+		_, _ = w.Write([]byte(schemaXML))
+	})
+
+	fds, err := conn.LayoutFields(context.Background(), "db", "customers")
+	assert.NoError(t, err)
+	assert.Equal(t, "0", max)
+	assert.Len(t, fds, 5)
+	assert.Equal(t, "orders::total", fds[4].Name)
+	assert.True(t, fds[0].NotEmpty)
+	assert.Equal(t, KindCalculation, fds[2].Kind)
+
+	yes := true
+	diff, err := conn.CheckSchema(context.Background(), "db", LayoutSchema{
+		Layout: "customers",
+		Fields: []FieldSchema{
+			{Name: "name", Type: TypeText, NotEmpty: &yes},
+			{Name: "phone", MaxRepeat: 2},
+			{Name: "total", Type: TypeNumber},
+		},
+	})
+	assert.NoError(t, err)
+	assert.False(t, diff.Breaking())
+	assert.Len(t, diff.Added, 2)
+
+	diff, err = conn.CheckSchema(context.Background(), "db", LayoutSchema{
+		Layout: "customers",
+		Fields: []FieldSchema{{Name: "name"}, {Name: "email"}},
+	})
+	var drift *SchemaDriftError
+	if assert.Error(t, err) {
+		assert.True(t, errors.As(err, &drift))
+		assert.Equal(t, diff, drift.Diff)
+	}
+	assert.Equal(t, []FieldSchema{{Name: "email"}}, diff.Removed)
+}
+
+func TestFieldDefinitions(t *testing.T) {
+	var rs FMResultset
+	assert.Nil(t, rs.FieldDefinitions())
+
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(schemaXML))
+	})
+	res, err := conn.Query(context.Background(), NewFMQuery("db", "customers", FindAll))
+	assert.NoError(t, err)
+	assert.Len(t, res.FieldDefinitions(), 5)
+}
+
+func TestLayoutFieldsEmptyLayout(t *testing.T) {
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Replace(schemaXML, `<error code="0"/>`, `<error code="401"/>`, 1)))
+	})
+
+	fds, err := conn.LayoutFields(context.Background(), "db", "customers")
+	assert.NoError(t, err)
+	assert.Len(t, fds, 5)
+}
@@ -0,0 +1,108 @@
+package gofmcon
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// ScriptOptions configures how RunScriptWithOptions passes
+// the parameter to the script and reads its result back
+type ScriptOptions struct {
+	// Encoding is used for parameters other than a string
+	Encoding ScriptParamEncoding
+	// ResultField is a field or a global field on the layout
+	// the script writes its result to
+	ResultField string
+	// ErrorField is a field or a global field on the layout
+	// the script writes Get(LastError) to
+	ErrorField string
+}
+
+// ScriptResult is the outcome of the script run
+type ScriptResult struct {
+	Resultset FMResultset
+	// Record is the record the script was run on
+	Record *Record
+	// Result is the value of ScriptOptions.ResultField
+	Result string
+	// ScriptError is the error code of ScriptOptions.ErrorField
+	ScriptError int
+}
+
+// ScriptError is returned when the script reports a non-zero error code.
+// It is different from FMError returned when the request itself fails
+type ScriptError struct {
+	Script string
+	Code   int
+}
+
+// Error includes the description of the code if it is a FileMaker error code,
+// scripts often report codes of their own
+func (e *ScriptError) Error() string {
+	msg := fmt.Sprintf("script %s failed: filemaker_error %d", e.Script, e.Code)
+	if desc, ok := FileMakerErrorCodes[e.Code]; ok && desc != "" {
+		msg += ": " + desc
+	}
+	return msg
+}
+
+// RunScript runs the script on a random record of the layout
+// with param encoded as JSON unless it is a string
+func (fmc *FMConnector) RunScript(ctx context.Context, database, layout, script string, param interface{}) (*ScriptResult, error) {
+	return fmc.RunScriptWithOptions(ctx, database, layout, script, param, ScriptOptions{})
+}
+
+// RunScriptWithOptions runs the script on a random record of the layout.
+// Failure of the request is returned as FMError, while an error code
+// the script writes to ScriptOptions.ErrorField is returned as ScriptError
+func (fmc *FMConnector) RunScriptWithOptions(ctx context.Context, database, layout, script string, param interface{}, opts ScriptOptions) (*ScriptResult, error) {
+	encoded, err := encodeScriptParam(param, opts.Encoding)
+	if err != nil {
+		return nil, fmt.Errorf("gofmcon.RunScript: %w", err)
+	}
+
+	rs, record, err := fmc.runScript(ctx, database, layout, script, encoded)
+	if err != nil {
+		return nil, fmt.Errorf("gofmcon.RunScript: %w", err)
+	}
+
+	res := &ScriptResult{Resultset: rs, Record: record}
+
+	if opts.ResultField != "" {
+		raw, ok := record.rawValue(opts.ResultField)
+		if !ok {
+			return res, fmt.Errorf("gofmcon.RunScript: result field %s is missing on layout %s", opts.ResultField, layout)
+		}
+		res.Result = raw
+	}
+
+	if opts.ErrorField != "" {
+		raw, ok := record.rawValue(opts.ErrorField)
+		if !ok {
+			return res, fmt.Errorf("gofmcon.RunScript: error field %s is missing on layout %s", opts.ErrorField, layout)
+		}
+		if raw != "" {
+			res.ScriptError, err = strconv.Atoi(raw)
+			if err != nil {
+				return res, fmt.Errorf("gofmcon.RunScript: error field %s is not a number: %w", opts.ErrorField, err)
+			}
+		}
+	}
+
+	if res.ScriptError != 0 {
+		return res, &ScriptError{Script: script, Code: res.ScriptError}
+	}
+
+	return res, nil
+}
+
+// DecodeJSON decodes the JSON result of the script into v
+func (r *ScriptResult) DecodeJSON(v interface{}) error {
+	return DecodeScriptJSON(r.Result, v)
+}
+
+// Values returns the result of the script decoded as a return-delimited list
+func (r *ScriptResult) Values() []string {
+	return DecodeScriptList(r.Result)
+}
@@ -0,0 +1,52 @@
+package gofmcon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunScript(t *testing.T) {
+	var gotParam string
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		gotParam = r.URL.Query().Get("-script.param")
+		switch r.URL.Query().Get("-lay") {
+		case "empty":
+			_, _ = w.Write([]byte(`<fmresultset><error code="401"/></fmresultset>`))
+		case "failing":
+			_, _ = w.Write([]byte(`<fmresultset><error code="0"/><resultset count="1" fetch-size="1"><record record-id="1">` +
+				`<field name="g_error"><data>301</data></field></record></resultset></fmresultset>`))
+		default:
+			_, _ = w.Write([]byte(scriptResultXML("g_result", "done")))
+		}
+	})
+	ctx := context.Background()
+
+	res, err := conn.RunScriptWithOptions(ctx, "db", "api", "do_it",
+		[]interface{}{"a\rb", 2.5, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+		ScriptOptions{Encoding: ScriptParamList, ResultField: "g_result"})
+	assert.NoError(t, err)
+	assert.Equal(t, "done", res.Result)
+	assert.Equal(t, "a\\rb\r2.5\r01/02/2020", gotParam)
+
+	_, err = conn.RunScript(ctx, "db", "api", "do_it", map[string]int{"id": 1})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":1}`, gotParam)
+
+	_, err = conn.RunScriptWithOptions(ctx, "db", "failing", "do_it", nil, ScriptOptions{ErrorField: "g_error"})
+	var scriptErr *ScriptError
+	assert.True(t, errors.As(err, &scriptErr))
+	assert.Equal(t, 301, scriptErr.Code)
+	assert.Equal(t, "script do_it failed: filemaker_error 301: "+FileMakerErrorCodes[301], scriptErr.Error())
+	assert.Equal(t, "script report failed: filemaker_error 50001", (&ScriptError{Script: "report", Code: 50001}).Error())
+
+	_, err = conn.RunScript(ctx, "db", "empty", "do_it", nil)
+	var fmErr *FMError
+	assert.True(t, errors.As(err, &fmErr))
+	assert.Equal(t, 401, fmErr.Code)
+	assert.False(t, errors.As(err, &scriptErr))
+}
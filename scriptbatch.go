@@ -0,0 +1,171 @@
+package gofmcon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ScriptOperation is a single record operation executed
+// by a FileMaker script as a part of ScriptBatch
+type ScriptOperation struct {
+	// Action is one of New, Edit, Delete or Duplicate
+	Action FMAction
+	// Layout overrides the layout of the batch for this operation
+	Layout   string
+	RecordID int
+	Fields   map[string]string
+}
+
+// ScriptOperationResult is the outcome of a single ScriptOperation
+// reported back by the script
+type ScriptOperationResult struct {
+	Error    int `json:"error"`
+	RecordID int `json:"recordId"`
+	ModID    int `json:"modId"`
+}
+
+// Err returns FileMaker error of the operation or nil if it succeeded
+func (r ScriptOperationResult) Err() error {
+	if r.Error == 0 {
+		return nil
+	}
+	return &FMError{Code: r.Error}
+}
+
+// ScriptBatch is a set of operations sent to a FileMaker script in one request,
+// so the script can apply them atomically and revert all of them on failure.
+//
+// The script receives a JSON parameter of the form
+//
+//	{"operations": [{"action": "new", "layout": "...", "recordId": 0, "fields": {"name": "value"}}]}
+//
+// where action is one of "new", "edit", "delete" or "dup", and must write
+//
+//	{"error": 0, "results": [{"error": 0, "recordId": 1, "modId": 0}]}
+//
+// into ResultField with one result per operation in the same order.
+// A non-zero top level error means the whole batch failed
+type ScriptBatch struct {
+	Database    string
+	Layout      string
+	Script      string
+	ResultField string
+	Operations  []ScriptOperation
+}
+
+// NewScriptBatch creates new ScriptBatch object
+func NewScriptBatch(database, layout, script, resultField string) *ScriptBatch {
+	return &ScriptBatch{
+		Database:    database,
+		Layout:      layout,
+		Script:      script,
+		ResultField: resultField,
+	}
+}
+
+// Add appends operations to the batch
+func (b *ScriptBatch) Add(ops ...ScriptOperation) *ScriptBatch {
+	b.Operations = append(b.Operations, ops...)
+	return b
+}
+
+type scriptBatchOperation struct {
+	Action   string            `json:"action"`
+	Layout   string            `json:"layout,omitempty"`
+	RecordID int               `json:"recordId,omitempty"`
+	Fields   map[string]string `json:"fields,omitempty"`
+}
+
+type scriptBatchParam struct {
+	Operations []scriptBatchOperation `json:"operations"`
+}
+
+type scriptBatchResult struct {
+	Error   int                     `json:"error"`
+	Results []ScriptOperationResult `json:"results"`
+}
+
+func (b *ScriptBatch) param() (string, error) {
+	param := scriptBatchParam{Operations: []scriptBatchOperation{}}
+	for i, op := range b.Operations {
+		switch op.Action {
+		case New, Edit, Delete, Duplicate:
+		default:
+			return "", fmt.Errorf("operation %d: unsupported action %q", i, op.Action)
+		}
+		if op.Action != New && op.RecordID <= 0 {
+			return "", fmt.Errorf("operation %d: %s requires record id", i, op.Action)
+		}
+		param.Operations = append(param.Operations, scriptBatchOperation{
+			Action:   strings.TrimPrefix(op.Action.String(), "-"),
+			Layout:   op.Layout,
+			RecordID: op.RecordID,
+			Fields:   op.Fields,
+		})
+	}
+
+	data, err := json.Marshal(param)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// ExecuteScriptBatch runs the script of the batch once with all its
+// operations and returns per-operation results reported by the script
+func (fmc *FMConnector) ExecuteScriptBatch(ctx context.Context, b *ScriptBatch) ([]ScriptOperationResult, error) {
+	if b.ResultField == "" {
+		return nil, errors.New("gofmcon.ExecuteScriptBatch: result field is not set")
+	}
+
+	param, err := b.param()
+	if err != nil {
+		return nil, fmt.Errorf("gofmcon.ExecuteScriptBatch: %w", err)
+	}
+
+	_, record, err := fmc.runScript(ctx, b.Database, b.Layout, b.Script, param)
+	if err != nil {
+		return nil, fmt.Errorf("gofmcon.ExecuteScriptBatch: %w", err)
+	}
+
+	raw, ok := record.rawValue(b.ResultField)
+	if !ok {
+		return nil, fmt.Errorf("gofmcon.ExecuteScriptBatch: result field %s is missing on layout %s", b.ResultField, b.Layout)
+	}
+
+	var res scriptBatchResult
+	err = json.Unmarshal([]byte(raw), &res)
+	if err != nil {
+		return nil, fmt.Errorf("gofmcon.ExecuteScriptBatch: error unmarshal script result: %w", err)
+	}
+
+	if res.Error != 0 {
+		return res.Results, fmt.Errorf("gofmcon.ExecuteScriptBatch: batch failed: %w", &FMError{Code: res.Error})
+	}
+
+	if len(res.Results) != len(b.Operations) {
+		return res.Results, fmt.Errorf("gofmcon.ExecuteScriptBatch: script returned %d results for %d operations", len(res.Results), len(b.Operations))
+	}
+
+	return res.Results, nil
+}
+
+// runScript runs the script by making a -findany request with it,
+// and returns the record the script ran on
+func (fmc *FMConnector) runScript(ctx context.Context, database, layout, script, param string) (FMResultset, *Record, error) {
+	q := NewFMQuery(database, layout, FindAny).WithPostFindScript(script, param)
+	rs, err := fmc.Query(ctx, q)
+	if err != nil {
+		return rs, nil, err
+	}
+
+	if rs.Resultset == nil || len(rs.Resultset.Records) == 0 {
+		return rs, nil, fmt.Errorf("no record returned from layout %s", layout)
+	}
+
+	return rs, rs.Resultset.Records[0], nil
+}
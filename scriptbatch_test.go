@@ -0,0 +1,70 @@
+package gofmcon
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scriptResultXML returns a resultset with one record
+// having the given value in the result field
+func scriptResultXML(field, value string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(value))
+	return `<fmresultset><error code="0"/><resultset count="1" fetch-size="1"><record record-id="1">` +
+		`<field name="` + field + `"><data>` + b.String() + `</data></field>` +
+		`</record></resultset></fmresultset>`
+}
+
+func TestExecuteScriptBatch(t *testing.T) {
+	var param scriptBatchParam
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.URL.RawQuery, "-findany")
+		assert.Equal(t, "apply_batch", r.URL.Query().Get("-script"))
+		assert.NoError(t, json.Unmarshal([]byte(r.URL.Query().Get("-script.param")), &param))
+		_, _ = w.Write([]byte(scriptResultXML("g_result",
+			`{"error":0,"results":[{"error":0,"recordId":10,"modId":0},{"error":0,"recordId":7,"modId":4}]}`)))
+	})
+
+	b := NewScriptBatch("db", "api", "apply_batch", "g_result").Add(
+		ScriptOperation{Action: New, Fields: map[string]string{"name": "John"}},
+		ScriptOperation{Action: Edit, RecordID: 7, Layout: "people", Fields: map[string]string{"name": "Jane"}},
+	)
+	results, err := conn.ExecuteScriptBatch(context.Background(), b)
+	assert.NoError(t, err)
+	assert.Equal(t, []ScriptOperationResult{{RecordID: 10}, {RecordID: 7, ModID: 4}}, results)
+
+	assert.Len(t, param.Operations, 2)
+	assert.Equal(t, "new", param.Operations[0].Action)
+	assert.Equal(t, "edit", param.Operations[1].Action)
+	assert.Equal(t, 7, param.Operations[1].RecordID)
+	assert.Equal(t, "people", param.Operations[1].Layout)
+}
+
+func TestExecuteScriptBatchFailure(t *testing.T) {
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(scriptResultXML("g_result",
+			`{"error":301,"results":[{"error":0,"recordId":10},{"error":301,"recordId":7}]}`)))
+	})
+
+	b := NewScriptBatch("db", "api", "apply_batch", "g_result").Add(
+		ScriptOperation{Action: New},
+		ScriptOperation{Action: Delete, RecordID: 7},
+	)
+	results, err := conn.ExecuteScriptBatch(context.Background(), b)
+	var fmErr *FMError
+	assert.True(t, errors.As(err, &fmErr))
+	assert.Equal(t, 301, fmErr.Code)
+	assert.Error(t, results[1].Err())
+
+	_, err = conn.ExecuteScriptBatch(context.Background(), NewScriptBatch("db", "api", "apply_batch", "g_result").Add(
+		ScriptOperation{Action: Edit},
+	))
+	assert.Error(t, err, "edit without record id must be rejected before sending")
+}
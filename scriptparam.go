@@ -0,0 +1,179 @@
+package gofmcon
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScriptParamEncoding is a way Go values are encoded into a script parameter
+type ScriptParamEncoding int
+
+const (
+	// ScriptParamJSON encodes the parameter as JSON,
+	// to be read with JSONGetElement on FileMaker side
+	ScriptParamJSON ScriptParamEncoding = iota
+	// ScriptParamList encodes slices as a return-delimited list,
+	// to be read with GetValue on FileMaker side. Backslashes, carriage
+	// returns and line feeds inside values are escaped as \\, \r and \n
+	ScriptParamList
+)
+
+const scriptListSeparator = "\r"
+
+var scriptListEscaper = strings.NewReplacer(`\`, `\\`, "\r", `\r`, "\n", `\n`)
+
+// EncodeScriptJSON encodes v as JSON to be passed as a script parameter
+func EncodeScriptJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("error marshal script param: %w", err)
+	}
+	return string(b), nil
+}
+
+// DecodeScriptJSON decodes JSON a script wrote into a field into v
+func DecodeScriptJSON(s string, v interface{}) error {
+	err := json.Unmarshal([]byte(s), v)
+	if err != nil {
+		return fmt.Errorf("error unmarshal script value: %w", err)
+	}
+	return nil
+}
+
+// EncodeScriptList encodes values as a return-delimited list, see ScriptParamList.
+// Values are formatted the way FileMaker expects them, times in
+// TimestampFormat or DateFormat and numbers without exponent
+func EncodeScriptList(values ...interface{}) (string, error) {
+	encoded := make([]string, len(values))
+	for i, v := range values {
+		str, err := formatFMValue(v)
+		if err != nil {
+			return "", fmt.Errorf("script param list value %d: %w", i, err)
+		}
+		encoded[i] = scriptListEscaper.Replace(str)
+	}
+
+	return strings.Join(encoded, scriptListSeparator), nil
+}
+
+// DecodeScriptList splits a return-delimited list a script wrote into
+// a field and unescapes its values, see ScriptParamList.
+// Both carriage returns and line feeds are treated as separators,
+// as XML parsing turns carriage returns into line feeds
+func DecodeScriptList(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	var values []string
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'r':
+				b.WriteByte('\r')
+			case 'n':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+		case c == '\r' && i+1 < len(s) && s[i+1] == '\n':
+			i++
+			fallthrough
+		case c == '\r' || c == '\n':
+			values = append(values, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return append(values, b.String())
+}
+
+// encodeScriptParam encodes v into a script parameter. Strings are
+// passed as they are, so a parameter can still be built by hand
+func encodeScriptParam(v interface{}, enc ScriptParamEncoding) (string, error) {
+	if str, ok := v.(string); ok {
+		return str, nil
+	}
+	if v == nil {
+		return "", nil
+	}
+
+	switch enc {
+	case ScriptParamJSON:
+		return EncodeScriptJSON(v)
+	case ScriptParamList:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return EncodeScriptList(v)
+		}
+		values := make([]interface{}, rv.Len())
+		for i := range values {
+			values[i] = rv.Index(i).Interface()
+		}
+		return EncodeScriptList(values...)
+	default:
+		return "", fmt.Errorf("unknown script param encoding %d", enc)
+	}
+}
+
+// formatFMValue formats a scalar Go value the way FileMaker expects it:
+// times in TimestampFormat, or DateFormat if they have no time of day,
+// numbers without exponent and booleans as 1 and 0
+func formatFMValue(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case time.Time:
+		if val.IsZero() {
+			return "", nil
+		}
+		if val.Hour() == 0 && val.Minute() == 0 && val.Second() == 0 && val.Nanosecond() == 0 {
+			return val.Format(DateFormat), nil
+		}
+		return val.Format(TimestampFormat), nil
+	case bool:
+		if val {
+			return "1", nil
+		}
+		return "0", nil
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	case fmt.Stringer:
+		return val.String(), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return "", nil
+		}
+		return formatFMValue(rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, rv.Type().Bits()), nil
+	case reflect.Bool:
+		return formatFMValue(rv.Bool())
+	case reflect.String:
+		return rv.String(), nil
+	default:
+		return "", fmt.Errorf("cannot format %T as FileMaker value", v)
+	}
+}
@@ -0,0 +1,42 @@
+package gofmcon
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptListRoundTrip(t *testing.T) {
+	values := []interface{}{"plain", "with\rreturn", `back\slash`, "line\nfeed", "", 42}
+	encoded, err := EncodeScriptList(values...)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"plain", "with\rreturn", `back\slash`, "line\nfeed", "", "42"}, DecodeScriptList(encoded))
+
+	// XML parsing turns the separators into line feeds
+	assert.Equal(t, []string{"a", "b\rc"}, DecodeScriptList("a\nb\\rc"))
+
+	_, err = EncodeScriptList(map[string]int{})
+	assert.Error(t, err)
+}
+
+func TestFMQueryStructuredScriptParams(t *testing.T) {
+	q := NewFMQuery("db", "layout", FindAll).
+		WithPreFindScriptJSON("prefind", map[string]interface{}{"ids": []int{1, 2}}).
+		WithPostFindScriptList("postfind", "a|b", 3)
+	values, err := url.ParseQuery(q.QueryString())
+	assert.NoError(t, err)
+	assert.Equal(t, `{"ids":[1,2]}`, values.Get("-script.prefind.param"))
+	assert.Equal(t, "a|b\r3", values.Get("-script.param"))
+	assert.NoError(t, q.Err())
+
+	var decoded struct{ IDs []int }
+	assert.NoError(t, DecodeScriptJSON(values.Get("-script.prefind.param"), &decoded))
+	assert.Equal(t, []int{1, 2}, decoded.IDs)
+
+	bad := NewFMQuery("db", "layout", FindAll).WithPostFindScriptJSON("postfind", make(chan int))
+	assert.Error(t, bad.Err())
+	_, err = NewFMConnector("localhost", "", "", "").Query(context.Background(), bad)
+	assert.Error(t, err)
+}
@@ -0,0 +1,47 @@
+package gofmcon
+
+import "sync"
+
+// flightCall is a request in flight shared by all callers with the same key
+type flightCall struct {
+	wg  sync.WaitGroup
+	rs  FMResultset
+	err error
+}
+
+// flightGroup collapses concurrent calls with the same key into one.
+// The zero value is ready to use
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// do executes fn once for all concurrent callers with the same key
+// and hands every caller the same result. The result must not be
+// mutated by callers, clone it first
+func (g *flightGroup) do(key string, fn func() (FMResultset, error)) (FMResultset, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.rs, c.err
+	}
+	c := &flightCall{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.rs, c.err = fn()
+
+	return c.rs, c.err
+}
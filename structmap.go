@@ -0,0 +1,347 @@
+package gofmcon
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// structField is a struct field mapped to a FileMaker field
+type structField struct {
+	index     []int
+	name      string
+	readOnly  bool
+	omitEmpty bool
+	// portal is the mapping of records of the related set named
+	// by the field, which is a slice of structs
+	portal *structMapping
+}
+
+type structMapping struct {
+	typ    reflect.Type
+	fields []structField
+	recID  []int
+	modID  []int
+}
+
+var structMappings sync.Map
+
+// mappingOf returns the mapping of the struct type t
+func mappingOf(t reflect.Type) (*structMapping, error) {
+	if m, ok := structMappings.Load(t); ok {
+		return m.(*structMapping), nil
+	}
+
+	building := map[reflect.Type]*structMapping{}
+	m, err := buildMapping(t, building)
+	if err != nil {
+		return nil, err
+	}
+
+	// mappings are cached only when all of them are complete, as
+	// they may refer to each other through portals
+	for typ, built := range building {
+		if typ != t {
+			structMappings.LoadOrStore(typ, built)
+		}
+	}
+	actual, _ := structMappings.LoadOrStore(t, m)
+	return actual.(*structMapping), nil
+}
+
+// buildMapping builds the mapping of t, building contains the mappings
+// being built, so portals of recursive types refer to them
+func buildMapping(t reflect.Type, building map[reflect.Type]*structMapping) (*structMapping, error) {
+	if m, ok := structMappings.Load(t); ok {
+		return m.(*structMapping), nil
+	}
+	if m, ok := building[t]; ok {
+		return m, nil
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s is not a struct", t)
+	}
+
+	m := &structMapping{typ: t}
+	building[t] = m
+	for _, sf := range reflect.VisibleFields(t) {
+		if !sf.IsExported() {
+			continue
+		}
+		tag, hasTag := sf.Tag.Lookup("fm")
+		if tag == "-" || (sf.Anonymous && !hasTag && sf.Type.Kind() == reflect.Struct) {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = sf.Name
+		}
+		f := structField{index: sf.Index, name: name}
+
+		isID := false
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "":
+			case "recid", "modid":
+				switch sf.Type.Kind() {
+				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				default:
+					return nil, fmt.Errorf("%s.%s: %s field must be an int", t, sf.Name, opt)
+				}
+				if opt == "recid" {
+					m.recID = sf.Index
+				} else {
+					m.modID = sf.Index
+				}
+				isID = true
+			case "readonly":
+				f.readOnly = true
+			case "omitempty":
+				f.omitEmpty = true
+			case "portal":
+				if sf.Type.Kind() != reflect.Slice || sf.Type.Elem().Kind() != reflect.Struct {
+					return nil, fmt.Errorf("%s.%s: portal field must be a slice of structs", t, sf.Name)
+				}
+				portal, err := buildMapping(sf.Type.Elem(), building)
+				if err != nil {
+					return nil, err
+				}
+				f.portal = portal
+			default:
+				return nil, fmt.Errorf("%s.%s: unknown fm tag option %q", t, sf.Name, opt)
+			}
+		}
+		if isID {
+			continue
+		}
+
+		if f.portal == nil && !isMappableType(sf.Type) {
+			return nil, fmt.Errorf("%s.%s: unsupported type %s", t, sf.Name, sf.Type)
+		}
+		m.fields = append(m.fields, f)
+	}
+	return m, nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func isMappableType(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// decode sets the fields of the struct v points to from the record.
+// Fields missing in the record are left as they are
+func (m *structMapping) decode(r *Record, v reflect.Value) error {
+	if m.recID != nil {
+		v.FieldByIndex(m.recID).SetInt(int64(r.ID))
+	}
+	if m.modID != nil {
+		v.FieldByIndex(m.modID).SetInt(int64(r.ModID))
+	}
+
+	for _, f := range m.fields {
+		if f.portal != nil {
+			err := f.portal.decodeRelatedSet(r, f.name, v.FieldByIndex(f.index))
+			if err != nil {
+				return fmt.Errorf("portal %s: %w", f.name, err)
+			}
+			continue
+		}
+		data, ok := r.fieldData(f.name)
+		if !ok {
+			continue
+		}
+		err := setFieldValue(v.FieldByIndex(f.index), data)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+func (m *structMapping) decodeRelatedSet(r *Record, table string, v reflect.Value) error {
+	for _, rs := range r.RelatedSet {
+		if !strings.EqualFold(rs.Table, table) {
+			continue
+		}
+		slice := reflect.MakeSlice(v.Type(), len(rs.Records), len(rs.Records))
+		for i, rr := range rs.Records {
+			err := m.decode(rr, slice.Index(i))
+			if err != nil {
+				return fmt.Errorf("record %d: %w", rr.ID, err)
+			}
+		}
+		v.Set(slice)
+	}
+	return nil
+}
+
+// encode returns the writable fields of the struct v as FMQueryFields,
+// repetitions are named as FileMaker expects, e.g. phone(2)
+func (m *structMapping) encode(v reflect.Value) ([]FMQueryField, error) {
+	var fields []FMQueryField
+	for _, f := range m.fields {
+		fv := v.FieldByIndex(f.index)
+		if f.readOnly || f.portal != nil || (f.omitEmpty && fv.IsZero()) {
+			continue
+		}
+
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+			for i := 0; i < fv.Len(); i++ {
+				value, err := formatFMValue(fv.Index(i).Interface())
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %w", f.name, err)
+				}
+				fields = append(fields, FMQueryField{Name: fmt.Sprintf("%s(%d)", f.name, i+1), Value: value})
+			}
+			continue
+		}
+
+		value, err := formatFMValue(fv.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.name, err)
+		}
+		fields = append(fields, FMQueryField{Name: f.name, Value: value})
+	}
+	return fields, nil
+}
+
+// fieldData returns all values of the field as they were sent by
+// FileMaker server, the name is matched ignoring case as FileMaker does
+func (r *Record) fieldData(name string) ([]string, bool) {
+	for _, f := range r.Fields {
+		if strings.EqualFold(f.Name, name) {
+			return f.Data, true
+		}
+	}
+	return nil, false
+}
+
+func setFieldValue(fv reflect.Value, data []string) error {
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(fv.Type(), len(data), len(data))
+		for i, s := range data {
+			err := setFieldValue(slice.Index(i), []string{s})
+			if err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+
+	var raw string
+	if len(data) > 0 {
+		raw = data[0]
+	}
+	s := strings.TrimSpace(raw)
+
+	if fv.Kind() == reflect.Ptr {
+		if s == "" {
+			fv.Set(reflect.Zero(fv.Type()))
+			return nil
+		}
+		ptr := reflect.New(fv.Type().Elem())
+		err := setFieldValue(ptr.Elem(), []string{raw})
+		if err != nil {
+			return err
+		}
+		fv.Set(ptr)
+		return nil
+	}
+
+	if fv.Type() == timeType {
+		t, err := parseFMTime(s)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	if s == "" && fv.Kind() != reflect.String {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			n, nerr := strconv.ParseFloat(s, 64)
+			if nerr != nil {
+				return fmt.Errorf("cannot parse %q as bool", s)
+			}
+			b = n != 0
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			f, ferr := strconv.ParseFloat(s, 64)
+			if ferr != nil || f != float64(int64(f)) {
+				return fmt.Errorf("cannot parse %q as int", s)
+			}
+			n = int64(f)
+		}
+		if fv.OverflowInt(n) {
+			return fmt.Errorf("%q overflows %s", s, fv.Type())
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("cannot parse %q as uint", s)
+		}
+		if fv.OverflowUint(n) {
+			return fmt.Errorf("%q overflows %s", s, fv.Type())
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot parse %q as float", s)
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+// parseFMTime parses a timestamp, a date or a time in FileMaker formats
+func parseFMTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{TimestampFormat, DateFormat, TimeFormat} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as time", s)
+}
@@ -0,0 +1,112 @@
+package gofmcon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// UpsertOptions configures UpsertWithOptions
+type UpsertOptions struct {
+	// CheckModID sends the mod id of the found record with the edit, so
+	// the upsert fails with ErrRecordModified if the record was changed
+	// between the find and the edit
+	CheckModID bool
+}
+
+// UpsertResult is the outcome of the upsert
+type UpsertResult struct {
+	Resultset FMResultset
+	// Record is the created or edited record
+	Record *Record
+	// Created is true if no record matched the key
+	Created bool
+}
+
+// Upsert edits the record of the layout whose key fields have the same values
+// as in values, or creates a new record if there is none. Values of all key
+// fields must be set. More than one matching record fails with ErrDuplicateKey
+func (fmc *FMConnector) Upsert(ctx context.Context, database, layout string, keyFields []string, values map[string]string) (*UpsertResult, error) {
+	return fmc.UpsertWithOptions(ctx, database, layout, keyFields, values, UpsertOptions{})
+}
+
+// UpsertWithOptions is Upsert configured with options
+func (fmc *FMConnector) UpsertWithOptions(ctx context.Context, database, layout string, keyFields []string, values map[string]string, opts UpsertOptions) (*UpsertResult, error) {
+	key, err := upsertKey(keyFields, values)
+	if err != nil {
+		return nil, fmt.Errorf("gofmcon.Upsert: %w", err)
+	}
+
+	found, err := fmc.findByKey(ctx, database, layout, key)
+	if err != nil {
+		return nil, fmt.Errorf("gofmcon.Upsert: %w", err)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fields := make([]FMQueryField, len(names))
+	for i, name := range names {
+		fields[i] = FMQueryField{Name: name, Value: values[name]}
+	}
+
+	q := NewFMQuery(database, layout, New).WithFields(fields...)
+	if found != nil {
+		q = NewFMQuery(database, layout, Edit).WithRecordID(found.ID).WithFields(fields...)
+		if opts.CheckModID {
+			q.WithModID(found.ModID)
+		}
+	}
+
+	rs, err := fmc.Query(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("gofmcon.Upsert: %w", err)
+	}
+
+	res := &UpsertResult{Resultset: rs, Created: found == nil}
+	if rs.Resultset != nil && len(rs.Resultset.Records) > 0 {
+		res.Record = rs.Resultset.Records[0]
+	}
+	return res, nil
+}
+
+// upsertKey makes the find fields matching the values of the key fields exactly
+func upsertKey(keyFields []string, values map[string]string) ([]FMQueryField, error) {
+	if len(keyFields) == 0 {
+		return nil, errors.New("no key fields")
+	}
+
+	key := make([]FMQueryField, len(keyFields))
+	for i, name := range keyFields {
+		v, ok := values[name]
+		if !ok {
+			return nil, fmt.Errorf("key field %s is missing", name)
+		}
+		if v == "" {
+			return nil, fmt.Errorf("key field %s is empty", name)
+		}
+		key[i] = Eq(v).For(name)
+	}
+	return key, nil
+}
+
+// findByKey returns the record matching the key, nil if there is none
+func (fmc *FMConnector) findByKey(ctx context.Context, database, layout string, key []FMQueryField) (*Record, error) {
+	rs, err := fmc.Query(ctx, NewFMQuery(database, layout, Find).WithFields(key...).Max(2))
+	if errors.Is(err, ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rs.Resultset == nil || len(rs.Resultset.Records) == 0 {
+		return nil, nil
+	}
+	if len(rs.Resultset.Records) > 1 {
+		return nil, ErrDuplicateKey
+	}
+	return rs.Resultset.Records[0], nil
+}
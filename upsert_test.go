@@ -0,0 +1,88 @@
+package gofmcon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpsert(t *testing.T) {
+	fake, conn := newFakeFileMaker(t)
+	fake.add(map[string]string{"code": "a1", "name": "Jane"})
+	fake.add(map[string]string{"code": "a2", "name": "John"})
+	fake.add(map[string]string{"code": "A2", "name": "Johnny"})
+	ctx := context.Background()
+
+	res, err := conn.Upsert(ctx, "db", "customers", []string{"code"}, map[string]string{"code": "a1", "name": "Jane Doe"})
+	assert.NoError(t, err)
+	assert.False(t, res.Created)
+	if assert.NotNil(t, res.Record) {
+		assert.Equal(t, 1, res.Record.ID)
+	}
+	assert.Equal(t, []string{"Jane Doe"}, fake.get(1).fields["name"])
+	if assert.Len(t, fake.queries, 2) {
+		assert.Equal(t, `code = "a1"`, fake.queries[0].FilterString())
+		assert.Equal(t, 2, fake.queries[0].MaxRecords)
+		assert.Equal(t, Edit, fake.queries[1].Action)
+		assert.Equal(t, 1, fake.queries[1].RecordID)
+		assert.Equal(t, 0, fake.queries[1].ModID)
+	}
+
+	res, err = conn.Upsert(ctx, "db", "customers", []string{"code"}, map[string]string{"code": "a3", "name": "Bob"})
+	assert.NoError(t, err)
+	assert.True(t, res.Created)
+	if assert.NotNil(t, res.Record) {
+		assert.Equal(t, 4, res.Record.ID)
+	}
+	assert.Equal(t, map[string][]string{"code": {"a3"}, "name": {"Bob"}}, fake.get(4).fields)
+
+	// the fake matches case-insensitively as FileMaker does
+	fake.queries = nil
+	_, err = conn.Upsert(ctx, "db", "customers", []string{"code"}, map[string]string{"code": "a2", "name": "J"})
+	assert.True(t, errors.Is(err, ErrDuplicateKey))
+	assert.Len(t, fake.queries, 1)
+
+	_, err = conn.Upsert(ctx, "db", "customers", []string{"code", "name"}, map[string]string{"code": "a1"})
+	assert.EqualError(t, err, "gofmcon.Upsert: key field name is missing")
+	_, err = conn.Upsert(ctx, "db", "customers", []string{"code"}, map[string]string{"code": ""})
+	assert.Error(t, err)
+	_, err = conn.Upsert(ctx, "db", "customers", nil, map[string]string{"code": "a1"})
+	assert.Error(t, err)
+	assert.Len(t, fake.queries, 1)
+}
+
+func TestUpsertCheckModID(t *testing.T) {
+	fake := &fakeFileMaker{nextID: 1}
+	rec := fake.add(map[string]string{"code": "a1", "name": "Jane"})
+	rec.modID = 3
+	var race bool
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		q, err := ParseFMQuery(r.URL.RawQuery)
+		if err != nil {
+			t.Errorf("invalid request %s: %v", r.URL.RawQuery, err)
+			return
+		}
+		resp := fake.handle(q)
+		if race && q.Action == Find {
+			// another user edits the record after it's found
+			rec.modID++
+		}
+		_, _ = w.Write([]byte(resp))
+	})
+	ctx := context.Background()
+	opts := UpsertOptions{CheckModID: true}
+
+	res, err := conn.UpsertWithOptions(ctx, "db", "customers", []string{"code"}, map[string]string{"code": "a1", "name": "Jane Doe"}, opts)
+	assert.NoError(t, err)
+	assert.False(t, res.Created)
+	assert.Equal(t, 3, fake.queries[1].ModID)
+	assert.Equal(t, 4, rec.modID)
+
+	race = true
+	_, err = conn.UpsertWithOptions(ctx, "db", "customers", []string{"code"}, map[string]string{"code": "a1", "name": "J"}, opts)
+	assert.True(t, errors.Is(err, ErrRecordModified))
+	assert.Equal(t, []string{"Jane Doe"}, rec.fields["name"])
+}
@@ -0,0 +1,298 @@
+package gofmcon
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ValidationError describes every problem found in FMQuery
+type ValidationError struct {
+	Problems []error
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Error()
+	}
+	return "invalid query: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the problems, so errors.Is and errors.As can match any of them
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+type queryValidator struct {
+	problems []error
+}
+
+func (v *queryValidator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Errorf(format, args...))
+}
+
+func (v *queryValidator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// Validate checks the query for problems FileMaker server would
+// otherwise report, or silently ignore, and returns ValidationError
+// listing all of them. Query validates every query before sending it
+func (q *FMQuery) Validate() error {
+	v := &queryValidator{}
+	q.validate(v)
+	return v.err()
+}
+
+// ValidateFields validates the query like Validate and also checks that
+// all fields used in the query are defined on the layout
+func (q *FMQuery) ValidateFields(layoutFields FieldsDefinitions) error {
+	return q.validateWithSchema(layoutFields, layoutFields)
+}
+
+func (q *FMQuery) validateWithSchema(layoutFields, responseFields FieldsDefinitions) error {
+	v := &queryValidator{}
+	q.validate(v)
+
+	if layoutFields != nil {
+		for _, g := range q.QueryFields {
+			for _, f := range g.Fields {
+				if f.Name != "" && !layoutFields.has(f.Name) {
+					v.addf("field %s is not on layout %s", f.Name, q.Layout)
+				}
+			}
+		}
+		if q.sortable() {
+			for _, sf := range q.SortFields {
+				if sf.Name != "" && !layoutFields.has(sf.Name) {
+					v.addf("sort field %s is not on layout %s", sf.Name, q.Layout)
+				}
+			}
+		}
+	}
+
+	if responseFields != nil {
+		for _, name := range q.ResponseFields {
+			if !responseFields.has(name) {
+				v.addf("response field %s is not on layout %s", name, q.responseLayout())
+			}
+		}
+	}
+
+	return v.err()
+}
+
+func (q *FMQuery) validate(v *queryValidator) {
+	if q.buildErr != nil {
+		v.problems = append(v.problems, q.buildErr)
+	}
+	for _, g := range q.QueryFields {
+		for _, f := range g.Fields {
+			if f.err != nil {
+				v.addf("field %s: %w", f.Name, f.err)
+			}
+		}
+	}
+	if q.Database == "" {
+		v.addf("database is empty")
+	}
+	if q.Layout == "" {
+		v.addf("layout is empty")
+	}
+
+	switch q.Action {
+	case Edit, Delete, Duplicate:
+		if q.RecordID == fmNoRecordID {
+			v.addf("%s requires record id", q.Action)
+		} else if q.RecordID <= 0 {
+			v.addf("invalid record id %d", q.RecordID)
+		}
+	case Find:
+		if q.RecordID != fmNoRecordID {
+			if q.RecordID <= 0 {
+				v.addf("invalid record id %d", q.RecordID)
+			}
+			if q.fieldsCount() > 0 {
+				v.addf("find by record id cannot have fields")
+			}
+		}
+	case FindAll, FindAny, New:
+	default:
+		v.addf("unknown action %q", q.Action)
+	}
+
+	if q.ModID < 0 {
+		v.addf("invalid mod id %d", q.ModID)
+	} else if q.ModID > 0 && q.Action != Edit {
+		v.addf("mod id cannot be used with %s", q.Action)
+	}
+
+	for _, g := range q.QueryFields {
+		switch g.Op {
+		case And, Or, Not:
+		default:
+			v.addf("unknown logical operator %q", g.Op)
+		}
+		for _, f := range g.Fields {
+			if f.Name == "" {
+				v.addf("field name is empty")
+			}
+			if f.Op == InRange && (f.Value == "" || f.ValueTo == "") {
+				v.addf("range of field %s must have both bounds", f.Name)
+			}
+		}
+	}
+
+	if len(q.SortFields) > 0 && !q.sortable() {
+		v.addf("sort fields cannot be used with %s", q.Action)
+	}
+	if len(q.SortFields) > fmMaxSortFields {
+		v.addf("%d sort fields given, FileMaker accepts at most %d", len(q.SortFields), fmMaxSortFields)
+	}
+	for _, sf := range q.SortFields {
+		if sf.Name == "" {
+			v.addf("sort field name is empty")
+		}
+		switch sf.Order {
+		case Ascending, Descending:
+			if sf.ValueList != "" {
+				v.addf("value list of field %s requires custom sort order", sf.Name)
+			}
+		case Custom:
+			if sf.ValueList == "" {
+				v.addf("custom sort order of field %s requires a value list", sf.Name)
+			}
+		default:
+			v.addf("unknown sort order %q of field %s", sf.Order, sf.Name)
+		}
+	}
+
+	if q.MaxRecords < 0 && q.MaxRecords != fmAllRecords {
+		v.addf("invalid max records %d", q.MaxRecords)
+	}
+	if q.SkipRecords < 0 {
+		v.addf("invalid skip records %d", q.SkipRecords)
+	}
+
+	if q.PreSortScript == "" && q.PreSortScriptParam != "" {
+		v.addf("presort script param is set without the script")
+	}
+	if q.PreFindScript == "" && q.PreFindScriptParam != "" {
+		v.addf("prefind script param is set without the script")
+	}
+	if q.PostFindScript == "" && q.PostFindScriptParam != "" {
+		v.addf("script param is set without the script")
+	}
+
+	keys := make([]string, 0, len(q.Query))
+	for key := range q.Query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if builderParam(key) {
+			v.addf("param %s is sent by the query itself and cannot be set with WithParam", key)
+		}
+	}
+}
+
+// builderParams are the parameters QueryString makes from the fields of FMQuery
+var builderParams = map[string]bool{
+	"-db": true, "-lay": true, "-lay.response": true, "-recid": true, "-modid": true,
+	"-max": true, "-skip": true, "-field": true, "-query": true,
+	"-script": true, "-script.param": true, "-script.prefind": true,
+	"-script.prefind.param": true, "-script.presort": true, "-script.presort.param": true,
+}
+
+// builderParam reports whether the parameter collides with one QueryString
+// makes itself, including the actions, sort fields and find fields. Names
+// without a leading - are field values of -new and -edit
+func builderParam(key string) bool {
+	if builderParams[key] || !strings.HasPrefix(key, "-") || isCompoundFieldKey(key) {
+		return true
+	}
+	if _, ok := fmActions[key]; ok {
+		return true
+	}
+	return strings.HasPrefix(key, "-sortfield.") || strings.HasPrefix(key, "-sortorder.")
+}
+
+// sortable reports whether sort fields are sent with the query
+func (q *FMQuery) sortable() bool {
+	return q.Action == FindAll || (q.Action == Find && q.RecordID == fmNoRecordID)
+}
+
+func (q *FMQuery) responseLayout() string {
+	if q.ResponseLayout != "" {
+		return q.ResponseLayout
+	}
+	return q.Layout
+}
+
+var repetitionSuffix = regexp.MustCompile(`\(\d+\)$`)
+
+// has reports whether the field is defined, ignoring case and
+// the repetition number, as FileMaker does. Related fields are always
+// reported as defined, as metadata describes only one related set
+func (fds FieldsDefinitions) has(name string) bool {
+	if strings.Contains(name, "::") {
+		return true
+	}
+	name = repetitionSuffix.ReplaceAllString(name, "")
+	for _, fd := range fds {
+		if strings.EqualFold(fd.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaCache keeps field definitions of layouts
+// learned from responses of FileMaker server
+type schemaCache struct {
+	mu      sync.RWMutex
+	layouts map[string]FieldsDefinitions
+}
+
+func schemaKey(database, layout string) string {
+	return strings.ToLower(database) + "\n" + strings.ToLower(layout)
+}
+
+func (c *schemaCache) get(database, layout string) FieldsDefinitions {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.layouts[schemaKey(database, layout)]
+}
+
+func (c *schemaCache) set(database, layout string, fds FieldsDefinitions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.layouts == nil {
+		c.layouts = map[string]FieldsDefinitions{}
+	}
+	c.layouts[schemaKey(database, layout)] = fds
+}
+
+// validate validates the query against the layouts the connector
+// has already seen responses from
+func (fmc *FMConnector) validate(q *FMQuery) error {
+	return q.validateWithSchema(
+		fmc.schemas.get(q.Database, q.Layout),
+		fmc.schemas.get(q.Database, q.responseLayout()),
+	)
+}
+
+// learnSchema remembers field definitions of the layout the response came from.
+// Responses limited to some fields describe only those fields, so they are skipped
+func (fmc *FMConnector) learnSchema(q *FMQuery, rs *FMResultset) {
+	if len(q.ResponseFields) > 0 || rs.MetaData == nil || len(rs.MetaData.FieldDefinitions) == 0 {
+		return
+	}
+	fmc.schemas.set(q.Database, q.responseLayout(), rs.MetaData.getAllFieldDefinitions())
+}
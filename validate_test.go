@@ -0,0 +1,74 @@
+package gofmcon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFMQueryValidate(t *testing.T) {
+	assert.NoError(t, NewFMQuery("db", "layout", FindAll).Validate())
+	assert.NoError(t, NewFMQuery("db", "layout", Find).WithRecordID(1).Validate())
+	assert.NoError(t, NewFMQuery("db", "layout", Edit).WithRecordID(1).WithFields(FMQueryField{Name: "a"}).Validate())
+
+	q := &FMQuery{
+		Action:             Find,
+		RecordID:           5,
+		QueryFields:        []FMQueryFieldGroup{{Op: And, Fields: []FMQueryField{{Name: "a"}, {Op: InRange, Value: "1"}}}},
+		SortFields:         []FMSortField{{Name: "a", Order: Custom}},
+		MaxRecords:         -5,
+		PreFindScriptParam: "x",
+	}
+	err := q.Validate()
+	var validationErr *ValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Len(t, validationErr.Problems, 9, err.Error())
+
+	err = NewFMQuery("db", "layout", Edit).Validate()
+	assert.EqualError(t, err, "invalid query: -edit requires record id")
+
+	err = NewFMQuery("db", "layout", Delete).WithRecordID(1).WithModID(2).Validate()
+	assert.EqualError(t, err, "invalid query: mod id cannot be used with -delete")
+	assert.NoError(t, NewFMQuery("db", "layout", Edit).WithRecordID(1).WithModID(2).Validate())
+
+	err = NewFMQuery("db", "layout", "-unknown").Validate()
+	assert.EqualError(t, err, `invalid query: unknown action "-unknown"`)
+
+	for _, key := range []string{"-db", "-lay", "-findall", "-find", "-script", "-sortfield.1", "-q1", "-q1.value", "name"} {
+		err = NewFMQuery("db", "layout", FindAll).WithParam(key, "x").Validate()
+		assert.EqualError(t, err, "invalid query: param "+key+" is sent by the query itself and cannot be set with WithParam")
+	}
+	assert.NoError(t, NewFMQuery("db", "layout", FindAll).WithParam("-lop", "or").WithParam("-quiet", "1").Validate())
+
+	err = NewFMQuery("db", "layout", Find).WithFilter("a = ").Validate()
+	var syntaxErr *FilterSyntaxError
+	assert.True(t, errors.As(err, &syntaxErr))
+}
+
+func TestQueryValidatesAgainstLearnedSchema(t *testing.T) {
+	var requests int32
+	conn := newTestConnector(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte(singleRecordXML))
+	})
+	ctx := context.Background()
+
+	_, err := conn.Query(ctx, NewFMQuery("db", "people", Edit))
+	assert.Error(t, err)
+	assert.EqualValues(t, 0, atomic.LoadInt32(&requests), "invalid query must not be sent")
+
+	unknown := NewFMQuery("db", "people", Find).WithFields(FMQueryField{Name: "nickname", Value: "J"})
+	_, err = conn.Query(ctx, unknown)
+	assert.NoError(t, err, "fields cannot be checked before the layout is known")
+
+	_, err = conn.Query(ctx, unknown)
+	assert.EqualError(t, err, "gofmcon.Query: invalid query: field nickname is not on layout people")
+
+	_, err = conn.Query(ctx, NewFMQuery("db", "people", Find).WithFields(FMQueryField{Name: "NAME", Value: "J"}))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&requests))
+}